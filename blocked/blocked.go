@@ -0,0 +1,144 @@
+package blocked
+
+import (
+	"encoding/binary"
+	"github.com/alxdavids/bloom-filter"
+	"log"
+	"math"
+)
+
+// BlockBits is the size of a block, one 64-byte cache line
+const BlockBits = 512
+
+const blockWords = BlockBits / 64
+
+// BlockedBloom confines the k probes of each key to a single 512-bit block,
+// so that Add and Check touch one cache line instead of k. Elements are not
+// spread evenly over the blocks, which raises the false-positive rate for a
+// given size; L compensates by adding blocks.
+type BlockedBloom struct {
+	bloom.Hasher          // hash function used for query and storage
+	L            uint     // Length of Bloom filter, a multiple of BlockBits
+	k            uint     // Number of hash functions
+	eps          float64  // false-positive probability
+	n            uint     // predicted size of set
+	bf           []uint64 // blocks of blockWords words
+	bs           []uint   // array of k bits from hash functions
+	c            uint     // count of elements in the Bloom filter
+}
+
+var _ bloom.Bloom = (*BlockedBloom)(nil)
+
+func New(n uint, eps float64) bloom.Bloom {
+	var (
+		k = K(eps)
+		L = L(eps, n)
+	)
+
+	return &BlockedBloom{
+		Hasher: bloom.DefaultHasher(),
+		k:      k,
+		L:      L,
+		eps:    eps,
+		n:      n,
+		bf:     make([]uint64, L/64),
+		bs:     make([]uint, uint(k)),
+	}
+}
+
+// K is the number of hash functions, the same as for a standard filter
+func K(eps float64) uint {
+	return bloom.K(eps)
+}
+
+// L is a multiple of BlockBits at which FalsePositiveRate does not exceed
+// eps, searched upwards from bloom.L in steps of about 1%
+func L(eps float64, n uint) uint {
+	k := K(eps)
+	blocks := (bloom.L(eps, n) + BlockBits - 1) / BlockBits
+	if blocks == 0 {
+		blocks = 1
+	}
+	for FalsePositiveRate(n, blocks*BlockBits, k) > eps {
+		// Grow by about 1% to keep the search short for large filters
+		blocks += blocks/100 + 1
+	}
+
+	return blocks * BlockBits
+}
+
+// FalsePositiveRate is the expected false-positive rate of a blocked filter
+// of length L with k hash functions holding n elements. The number of
+// elements in a block is approximately Poisson distributed.
+func FalsePositiveRate(n, L, k uint) float64 {
+	if L < BlockBits {
+		return 1
+	}
+	if n == 0 {
+		return 0
+	}
+	lambda := float64(n) / float64(L/BlockBits)
+	max := int(lambda + 10*math.Sqrt(lambda) + 10)
+
+	fpr := 0.0
+	for i := 0; i <= max; i++ {
+		lg, _ := math.Lgamma(float64(i) + 1)
+		p := math.Exp(float64(i)*math.Log(lambda) - lambda - lg)
+		// Probability that a bit in a block with i elements is set
+		set := 1 - math.Pow(1-1.0/BlockBits, float64(k)*float64(i))
+		fpr += p * math.Pow(set, float64(k))
+	}
+
+	return fpr
+}
+
+func (this *BlockedBloom) Add(key []byte) bloom.Bloom {
+	b := this.setBitset(key)
+	for _, v := range this.bs[:this.k] {
+		this.bf[b+v/64] |= 1 << (v % 64)
+	}
+
+	this.c++
+	if this.c > this.n {
+		log.Println("Adding a greater number of elements than are expected. Expect failure.")
+	}
+
+	return this
+}
+
+func (this *BlockedBloom) Check(key []byte) bool {
+	b := this.setBitset(key)
+	for _, v := range this.bs[:this.k] {
+		if this.bf[b+v/64]&(1<<(v%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (this *BlockedBloom) Reset() {
+	this.k = K(this.eps)
+	this.L = L(this.eps, this.n)
+	this.bf = make([]uint64, this.L/64)
+	this.bs = make([]uint, this.k)
+	this.c = 0
+	this.Hasher = bloom.DefaultHasher()
+}
+
+// Count is the number of elements added
+func (this *BlockedBloom) Count() uint {
+	return this.c
+}
+
+// setBitset fills bs with the k bit offsets inside the key's block and
+// returns the index of the block's first word
+func (this *BlockedBloom) setBitset(key []byte) uint {
+	s := this.Sum(key)
+	// The block comes from the second half of the digest and the offsets
+	// from enhanced double hashing over the two 32-bit words of the first
+	b := binary.BigEndian.Uint64(s[8:16]) % uint64(this.L/BlockBits)
+	bloom.Indices(s[0:8], BlockBits, this.bs[:this.k])
+
+	return uint(b) * blockWords
+}
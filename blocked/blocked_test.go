@@ -0,0 +1,124 @@
+package blocked
+
+import (
+	"fmt"
+	"github.com/alxdavids/bloom-filter"
+	"github.com/alxdavids/bloom-filter/standard"
+	"log"
+	"math/big"
+	"math/bits"
+	"testing"
+)
+
+var (
+	n   uint = 10000
+	eps      = 0.01
+)
+
+func TestBlocked(t *testing.T) {
+	bbf := New(n, eps).(*BlockedBloom)
+	if bbf.L%BlockBits != 0 || bbf.L < bloom.L(eps, n) {
+		log.Fatalln("Unexpected length", bbf.L)
+	}
+	if FalsePositiveRate(n, bbf.L, bbf.k) > eps {
+		log.Fatalln("Expected false-positive rate exceeds eps")
+	}
+
+	for i := int64(0); i < int64(n); i++ {
+		bbf.Add(big.NewInt(i).Bytes())
+	}
+	for i := int64(0); i < int64(n); i++ {
+		if !bbf.Check(big.NewInt(i).Bytes()) {
+			log.Fatalln("Key not found in blocked Bloom filter", i)
+		}
+	}
+
+	fp := 0
+	trials := int64(100000)
+	for i := int64(n); i < int64(n)+trials; i++ {
+		if bbf.Check(big.NewInt(i).Bytes()) {
+			fp++
+		}
+	}
+	if rate := float64(fp) / float64(trials); rate > 1.5*eps {
+		log.Fatalln("False-positive rate too high:", rate)
+	}
+
+	bbf.Reset()
+	if bbf.Count() != 0 || bbf.Check(big.NewInt(0).Bytes()) {
+		log.Fatalln("Reset did not clear the filter")
+	}
+}
+
+func BenchmarkCheck(b *testing.B) {
+	for _, bn := range []uint{10000, 1000000} {
+		filters := map[string]bloom.Bloom{
+			"standard": standard.New(bn, eps),
+			"blocked":  New(bn, eps),
+		}
+		for _, name := range []string{"standard", "blocked"} {
+			bf := filters[name]
+			for i := int64(0); i < int64(bn); i++ {
+				bf.Add(big.NewInt(i).Bytes())
+			}
+			keys := make([][]byte, 1024)
+			for i := range keys {
+				keys[i] = big.NewInt(int64(i) * 7919).Bytes()
+			}
+
+			b.Run(fmt.Sprintf("n=%d/%s", bn, name), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					bf.Check(keys[i%len(keys)])
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkAdd(b *testing.B) {
+	bn := uint(1000000)
+	for _, name := range []string{"standard", "blocked"} {
+		b.Run(name, func(b *testing.B) {
+			var bf bloom.Bloom
+			if name == "standard" {
+				bf = standard.New(bn, eps)
+			} else {
+				bf = New(bn, eps)
+			}
+			for i := 0; i < b.N; i++ {
+				// Stay within capacity to avoid the overfill warning
+				if i%int(bn) == 0 {
+					bf.Reset()
+				}
+				bf.Add(big.NewInt(int64(i)).Bytes())
+			}
+		})
+	}
+}
+
+// fixedHash returns the same digest for every key
+type fixedHash struct {
+	sum []byte
+}
+
+func (this *fixedHash) Write(p []byte) (int, error) { return len(p), nil }
+func (this *fixedHash) Sum(b []byte) []byte         { return append(b, this.sum...) }
+func (this *fixedHash) Reset()                      {}
+func (this *fixedHash) Size() int                   { return len(this.sum) }
+func (this *fixedHash) BlockSize() int              { return 1 }
+
+// With the second word a multiple of BlockBits, plain double hashing would
+// set a single bit. Enhanced double hashing repeats only the first index.
+func TestBlockedDegenerateDigest(t *testing.T) {
+	bbf := New(n, eps).(*BlockedBloom)
+	bbf.SetHasher(&fixedHash{sum: []byte{0, 0, 0, 5, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0}})
+	bbf.Add([]byte("key"))
+
+	set := 0
+	for _, w := range bbf.bf[:blockWords] {
+		set += bits.OnesCount64(w)
+	}
+	if set < int(bbf.k)-1 {
+		log.Fatalln("Too few distinct bits set:", set, bbf.k)
+	}
+}
@@ -1,6 +1,7 @@
 package bloom
 
 import (
+	"encoding/binary"
 	"hash"
 	"math"
 )
@@ -12,10 +13,70 @@ type Bloom interface {
 	Reset()
 }
 
+// HasherID records which hash function a filter was built with, so that
+// serialized filters can be restored with the same hasher.
+type HasherID uint8
+
+const (
+	HasherCustom     HasherID = iota // arbitrary hash.Hash given to SetHasher
+	HasherMMH3                       // mmh3.New128, the default
+	HasherSHA256                     // sha256.New
+	HasherHMACSHA256                 // hmac.New(sha256.New, key), keyed
+	HasherFNV128a                    // fnv.New128a, fast and non-cryptographic
+)
+
+// Layout records how a filter maps the k hash values of a key to bits, so
+// that an encrypted copy of the filter can be queried the same way.
+type Layout uint8
+
+const (
+	LayoutStandard    Layout = iota // every index ranges over the whole filter
+	LayoutPartitioned               // index i falls in the i-th of k slices of L/k bits
+)
+
+// MaxK bounds the number of hash functions accepted from serialized filters.
+// It is well above K of the smallest positive float64, 1075.
+const MaxK = 1 << 11
+
+// K is the optimal number of hash functions for eps, rounded up and at least
+// one. Use Plan to validate eps.
 func K(eps float64) uint {
-	return uint(math.Floor(math.Log2(1 / eps)))
+	return uint(math.Max(1, math.Ceil(math.Log2(1/eps))))
 }
 
+// L is the smallest length in bits at which n elements with K(eps) hash
+// functions have a false-positive rate of at most eps. This is within a few
+// bits of the optimal n*log2(e)*log2(1/eps), which undershoots once k is
+// rounded up. Use Plan to validate eps.
 func L(eps float64, n uint) uint {
-	return uint(math.Floor(float64(n) * math.Log2(math.E) * math.Log2(1/eps)))
+	if eps >= 1 {
+		return 1
+	}
+	k := float64(K(eps))
+	return uint(math.Max(1, math.Ceil(-k*float64(n)/math.Log(1-math.Pow(eps, 1/k)))))
+}
+
+// Indices fills bs with indices in [0, m) for the digest s of a key using
+// enhanced double hashing over two 64-bit halves of s, so filters longer than
+// 2^32 bits are covered evenly. Digests shorter than 16 bytes fall back to
+// two 32-bit words and must be at least 8 bytes.
+//
+// Reference: Dillinger and Manolios, Bloom Filters in Probabilistic
+// Verification, FMCAD 2004
+func Indices(s []byte, m uint, bs []uint) {
+	var h1, h2 uint64
+	if len(s) >= 16 {
+		h1 = binary.BigEndian.Uint64(s[0:8])
+		h2 = binary.BigEndian.Uint64(s[8:16])
+	} else {
+		h1 = uint64(binary.BigEndian.Uint32(s[0:4]))
+		h2 = uint64(binary.BigEndian.Uint32(s[4:8]))
+	}
+
+	x, y := h1%uint64(m), h2%uint64(m)
+	for i := range bs {
+		bs[i] = uint(x)
+		x = (x + y) % uint64(m)
+		y = (y + uint64(i) + 1) % uint64(m)
+	}
 }
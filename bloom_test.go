@@ -0,0 +1,68 @@
+package bloom
+
+import (
+	"github.com/reusee/mmh3"
+	"log"
+	"math"
+	"math/big"
+	"testing"
+)
+
+// Chi-squared test of the indices of many keys over buckets of a filter
+// much longer than 2^32 bits
+func TestIndicesDistribution(t *testing.T) {
+	var (
+		L       = uint(3) << 34
+		k       = 8
+		buckets = 64
+		keys    = 50000
+	)
+
+	counts := make([]float64, buckets)
+	above := 0
+	bs := make([]uint, k)
+	h := mmh3.New128()
+	for i := 0; i < keys; i++ {
+		h.Reset()
+		h.Write(big.NewInt(int64(i)).Bytes())
+		Indices(h.Sum(nil), L, bs)
+		for _, v := range bs {
+			if v >= L {
+				log.Fatalln("Index out of range:", v)
+			}
+			if v >= 1<<32 {
+				above++
+			}
+			counts[uint64(v)*uint64(buckets)/uint64(L)]++
+		}
+	}
+
+	total := float64(keys * k)
+	expected := total / float64(buckets)
+	chi2 := 0.0
+	for _, c := range counts {
+		chi2 += (c - expected) * (c - expected) / expected
+	}
+	// 63 degrees of freedom, p = 0.001
+	if chi2 > 103.4 {
+		log.Fatalln("Indices are not uniform, chi-squared:", chi2)
+	}
+
+	// Only 1/48 of the filter lies below 2^32
+	want := 1 - float64(uint(1)<<32)/float64(L)
+	if got := float64(above) / total; math.Abs(got-want) > 0.005 {
+		log.Fatalln("Wrong share of indices above 2^32:", got, want)
+	}
+}
+
+// Index i is h1 + i*h2 + (i^3-i)/6 mod m, so consecutive differences are
+// not constant as in plain double hashing
+func TestIndicesSequence(t *testing.T) {
+	bs := make([]uint, 6)
+	Indices([]byte{0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 3}, 1000, bs)
+	for i, want := range []uint{1, 4, 8, 14, 23, 36} {
+		if bs[i] != want {
+			log.Fatalln("Unexpected enhanced double hashing sequence:", bs)
+		}
+	}
+}
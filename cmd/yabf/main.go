@@ -0,0 +1,282 @@
+// Command yabf builds, queries and combines Bloom filters stored in the
+// serialized StandardBloom format, and runs the encrypted set operations.
+//
+//	yabf build -n 1000 -eps 0.001 -in set.txt -out set.bf
+//	yabf query -filter set.bf < keys.txt
+//	yabf stats -filter set.bf
+//	yabf merge -out all.bf a.bf b.bf
+//
+// The encrypted set operations of encbf are split into steps that can run on
+// different machines. The Owner of set.bf runs keygen, encrypt and decrypt,
+// the evaluator holding other.txt runs evaluate.
+//
+//	yabf keygen -bits 2048 -out owner.key
+//	yabf encrypt -key owner.key -filter set.bf -mode psi -out set.ebf
+//	yabf evaluate -filter set.ebf -in other.txt -out results.bin
+//	yabf decrypt -key owner.key -mode psi -in results.bin
+//
+// Elements are the lines of a file, without the line ending.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/alxdavids/bloom-filter"
+	"github.com/alxdavids/bloom-filter/standard"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+var ErrUsage = errors.New("usage: yabf build|query|stats|merge|keygen|encrypt|evaluate|decrypt [flags]")
+
+type command func(args []string, stdin io.Reader, stdout io.Writer) error
+
+var commands = map[string]command{
+	"build":    build,
+	"query":    query,
+	"stats":    stats,
+	"merge":    merge,
+	"keygen":   keygen,
+	"encrypt":  encrypt,
+	"evaluate": evaluate,
+	"decrypt":  decrypt,
+}
+
+func main() {
+	if e := run(os.Args[1:], os.Stdin, os.Stdout); e != nil {
+		fmt.Fprintln(os.Stderr, "yabf:", e)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return ErrUsage
+	}
+
+	return cmd(args[1:], stdin, stdout)
+}
+
+// hasherFlags are shared by the commands that build or load filters. name is
+// nil for commands that load filters, which record their hasher.
+type hasherFlags struct {
+	name    *string
+	keyFile *string
+}
+
+func addHasherFlags(fs *flag.FlagSet) hasherFlags {
+	hf := addHasherKeyFlag(fs)
+	hf.name = fs.String("hasher", bloom.HasherMMH3.String(), "hash function, one of mmh3-128, sha256, hmac-sha256, fnv128a")
+
+	return hf
+}
+
+func addHasherKeyFlag(fs *flag.FlagSet) hasherFlags {
+	return hasherFlags{
+		keyFile: fs.String("hasher-key", "", "file holding the key of a keyed hasher"),
+	}
+}
+
+func (this hasherFlags) key() ([]byte, error) {
+	if *this.keyFile == "" {
+		return nil, nil
+	}
+
+	return ioutil.ReadFile(*this.keyFile)
+}
+
+func build(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("build", flag.ContinueOnError)
+	n := fs.Uint("n", 0, "expected number of elements, defaults to the number of lines in -in")
+	eps := fs.Float64("eps", 0.001, "target false-positive rate")
+	in := fs.String("in", "-", "newline-delimited elements, - for stdin")
+	out := fs.String("out", "", "file to write the filter to")
+	hf := addHasherFlags(fs)
+	if e := fs.Parse(args); e != nil {
+		return e
+	}
+	if *out == "" {
+		return errors.New("build: -out is required")
+	}
+
+	r, e := open(*in, stdin)
+	if e != nil {
+		return e
+	}
+	defer r.Close()
+	keys, e := readLines(r)
+	if e != nil {
+		return e
+	}
+	if *n == 0 {
+		*n = uint(len(keys))
+	}
+	if *n == 0 {
+		return errors.New("build: no elements in -in and no -n given")
+	}
+	if _, e := bloom.Plan(bloom.Params{N: *n, Eps: *eps}); e != nil {
+		return e
+	}
+
+	sbf := standard.New(*n, *eps).(*standard.StandardBloom)
+	id, e := bloom.HasherByName(*hf.name)
+	if e != nil {
+		return e
+	}
+	hkey, e := hf.key()
+	if e != nil {
+		return e
+	}
+	if e := sbf.UseHasher(id, hkey); e != nil {
+		return e
+	}
+	for _, key := range keys {
+		sbf.Add(key)
+	}
+
+	return writeFilter(*out, sbf)
+}
+
+func query(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	filter := fs.String("filter", "", "filter file written by build or merge")
+	hf := addHasherKeyFlag(fs)
+	if e := fs.Parse(args); e != nil {
+		return e
+	}
+	sbf, e := readFilter(*filter, hf)
+	if e != nil {
+		return e
+	}
+
+	keys, e := readLines(stdin)
+	if e != nil {
+		return e
+	}
+	w := bufio.NewWriter(stdout)
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s\t%v\n", key, sbf.Check(key))
+	}
+
+	return w.Flush()
+}
+
+func stats(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	filter := fs.String("filter", "", "filter file written by build or merge")
+	hf := addHasherKeyFlag(fs)
+	if e := fs.Parse(args); e != nil {
+		return e
+	}
+	sbf, e := readFilter(*filter, hf)
+	if e != nil {
+		return e
+	}
+
+	_, L, k, n, eps, bf := sbf.GetParams()
+	fmt.Fprintf(stdout, "hasher: %v\n", sbf.HasherID())
+	fmt.Fprintf(stdout, "L: %v\nk: %v\nn: %v\neps: %v\n", L, k, n, eps)
+	fmt.Fprintf(stdout, "adds: %v\n", sbf.Count())
+	fmt.Fprintf(stdout, "fill ratio: %.4f\n", float64(bf.Cardinality())/float64(L))
+	fmt.Fprintf(stdout, "estimated count: %.1f\n", sbf.EstimateCount())
+	fmt.Fprintf(stdout, "estimated false-positive rate: %.6g\n", sbf.EstimateFalsePositiveRate())
+	if sbf.EstimateFalsePositiveRate() > eps {
+		fmt.Fprintln(stdout, "warning: filter is saturated")
+	}
+
+	return nil
+}
+
+func merge(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("merge", flag.ContinueOnError)
+	out := fs.String("out", "", "file to write the merged filter to")
+	intersect := fs.Bool("intersect", false, "intersect the filters instead of taking their union")
+	hf := addHasherKeyFlag(fs)
+	if e := fs.Parse(args); e != nil {
+		return e
+	}
+	if *out == "" || fs.NArg() < 2 {
+		return errors.New("merge: -out and at least two filters are required")
+	}
+
+	merged, e := readFilter(fs.Arg(0), hf)
+	if e != nil {
+		return e
+	}
+	for _, file := range fs.Args()[1:] {
+		sbf, e := readFilter(file, hf)
+		if e != nil {
+			return e
+		}
+		if *intersect {
+			merged, e = merged.Intersect(sbf)
+		} else {
+			merged, e = merged.Union(sbf)
+		}
+		if e != nil {
+			return fmt.Errorf("%s: %v", file, e)
+		}
+	}
+
+	return writeFilter(*out, merged)
+}
+
+func readFilter(file string, hf hasherFlags) (*standard.StandardBloom, error) {
+	if file == "" {
+		return nil, errors.New("a filter file is required")
+	}
+	data, e := ioutil.ReadFile(file)
+	if e != nil {
+		return nil, e
+	}
+	hkey, e := hf.key()
+	if e != nil {
+		return nil, e
+	}
+	sbf := &standard.StandardBloom{}
+	if e := sbf.UnmarshalKeyed(data, hkey); e != nil {
+		return nil, fmt.Errorf("%s: %v", file, e)
+	}
+
+	return sbf, nil
+}
+
+func writeFilter(file string, sbf *standard.StandardBloom) error {
+	data, e := sbf.MarshalBinary()
+	if e != nil {
+		return e
+	}
+
+	return ioutil.WriteFile(file, data, 0644)
+}
+
+func open(file string, stdin io.Reader) (io.ReadCloser, error) {
+	if file == "-" {
+		return ioutil.NopCloser(stdin), nil
+	}
+
+	return os.Open(file)
+}
+
+// readLines returns the non-empty lines of r
+func readLines(r io.Reader) ([][]byte, error) {
+	var keys [][]byte
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		line := strings.TrimSuffix(sc.Text(), "\r")
+		if line != "" {
+			keys = append(keys, []byte(line))
+		}
+	}
+
+	return keys, sc.Err()
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runOut runs a command and returns its output
+func runOut(stdin string, args ...string) string {
+	var out bytes.Buffer
+	if e := run(args, strings.NewReader(stdin), &out); e != nil {
+		log.Fatalln(args, e)
+	}
+
+	return out.String()
+}
+
+func writeTemp(dir, name, content string) string {
+	file := filepath.Join(dir, name)
+	if e := ioutil.WriteFile(file, []byte(content), 0644); e != nil {
+		log.Fatalln(e)
+	}
+
+	return file
+}
+
+func TestCLI(t *testing.T) {
+	dir, e := ioutil.TempDir("", "yabf")
+	if e != nil {
+		log.Fatalln(e)
+	}
+	defer os.RemoveAll(dir)
+
+	a := writeTemp(dir, "a.txt", "apple\nbanana\r\ncherry\n\n")
+	key := writeTemp(dir, "key", "secret")
+	abf := filepath.Join(dir, "a.bf")
+	bbf := filepath.Join(dir, "b.bf")
+
+	runOut("", "build", "-n", "10", "-in", a, "-out", abf)
+	runOut(strings.Join([]string{"cherry", "date"}, "\n"), "build", "-n", "10", "-out", bbf)
+
+	got := runOut("banana\nfig\n", "query", "-filter", abf)
+	if got != "banana\ttrue\nfig\tfalse\n" {
+		log.Fatalln("Unexpected query output:", got)
+	}
+
+	if st := runOut("", "stats", "-filter", abf); !strings.Contains(st, "adds: 3") || !strings.Contains(st, "estimated count: ") {
+		log.Fatalln("Unexpected stats output:", st)
+	}
+
+	union := filepath.Join(dir, "union.bf")
+	runOut("", "merge", "-out", union, abf, bbf)
+	got = runOut("apple\ndate\n", "query", "-filter", union)
+	if got != "apple\ttrue\ndate\ttrue\n" {
+		log.Fatalln("Unexpected union query output:", got)
+	}
+	inter := filepath.Join(dir, "inter.bf")
+	runOut("", "merge", "-intersect", "-out", inter, abf, bbf)
+	got = runOut("cherry\napple\n", "query", "-filter", inter)
+	if got != "cherry\ttrue\napple\tfalse\n" {
+		log.Fatalln("Unexpected intersection query output:", got)
+	}
+
+	// Keyed filters need the key to be read and cannot merge with others
+	kbf := filepath.Join(dir, "k.bf")
+	runOut("", "build", "-in", a, "-out", kbf, "-n", "10", "-hasher", "hmac-sha256", "-hasher-key", key)
+	if e := run([]string{"query", "-filter", kbf}, strings.NewReader(""), ioutil.Discard); e == nil {
+		log.Fatalln("Keyed filter should not load without its key")
+	}
+	got = runOut("apple\n", "query", "-filter", kbf, "-hasher-key", key)
+	if got != "apple\ttrue\n" {
+		log.Fatalln("Unexpected keyed query output:", got)
+	}
+	// Loaded filters record their hasher, so only build takes -hasher
+	if e := run([]string{"query", "-filter", kbf, "-hasher", "sha256"}, strings.NewReader(""), ioutil.Discard); e == nil {
+		log.Fatalln("query should not accept -hasher")
+	}
+
+	if e := run([]string{"nope"}, nil, ioutil.Discard); e != ErrUsage {
+		log.Fatalln("Expected usage error, got", e)
+	}
+}
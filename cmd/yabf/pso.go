@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/alxdavids/bloom-filter/encbf"
+	"github.com/alxdavids/bloom-filter/protocol"
+	"io"
+	"io/ioutil"
+	"runtime"
+)
+
+var modes = map[string]int{
+	"psu": encbf.ModePSU,
+	"psi": encbf.ModePSI,
+	"ca":  encbf.ModeCA,
+}
+
+func parseMode(name string) (int, error) {
+	mode, ok := modes[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown mode %q, use psu, psi or ca", name)
+	}
+
+	return mode, nil
+}
+
+// keygen writes a new Owner key
+func keygen(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("keygen", flag.ContinueOnError)
+	scheme := fs.String("scheme", "paillier", "paillier, or elgamal for ca only")
+	bits := fs.Int("bits", 2048, "Paillier modulus size")
+	out := fs.String("out", "", "file to write the private key to")
+	if e := fs.Parse(args); e != nil {
+		return e
+	}
+	if *out == "" {
+		return errors.New("keygen: -out is required")
+	}
+
+	var s encbf.Scheme
+	switch *scheme {
+	case "paillier":
+		s = &encbf.Paillier{}
+	case "elgamal":
+		s = &encbf.ElGamal{}
+	default:
+		return fmt.Errorf("keygen: unknown scheme %q", *scheme)
+	}
+	owner, e := encbf.NewOwnerWithScheme(s, *bits)
+	if e != nil {
+		return e
+	}
+	data, e := owner.MarshalBinary()
+	if e != nil {
+		return e
+	}
+
+	return ioutil.WriteFile(*out, data, 0600)
+}
+
+// encrypt turns a filter written by build into an encrypted filter for the
+// evaluator
+func encrypt(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("encrypt", flag.ContinueOnError)
+	key := fs.String("key", "", "private key written by keygen")
+	filter := fs.String("filter", "", "filter file written by build or merge")
+	modeName := fs.String("mode", "psi", "psu, psi or ca")
+	out := fs.String("out", "", "file to write the encrypted filter to")
+	workers := fs.Int("workers", runtime.NumCPU(), "maximum concurrent encryptions")
+	hf := addHasherKeyFlag(fs)
+	if e := fs.Parse(args); e != nil {
+		return e
+	}
+	if *out == "" {
+		return errors.New("encrypt: -out is required")
+	}
+	if *workers < 1 {
+		return errors.New("encrypt: -workers must be at least 1")
+	}
+	mode, e := parseMode(*modeName)
+	if e != nil {
+		return e
+	}
+	owner, e := readOwner(*key)
+	if e != nil {
+		return e
+	}
+	sbf, e := readFilter(*filter, hf)
+	if e != nil {
+		return e
+	}
+
+	eb, e := owner.EncryptFast(sbf, mode, *workers)
+	if e != nil {
+		return e
+	}
+	data, e := eb.MarshalBinary()
+	if e != nil {
+		return e
+	}
+
+	return ioutil.WriteFile(*out, data, 0644)
+}
+
+// evaluate combines the encrypted filter with the elements of a file and
+// writes the ciphertexts to return to the Owner
+func evaluate(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("evaluate", flag.ContinueOnError)
+	filter := fs.String("filter", "", "encrypted filter written by encrypt")
+	in := fs.String("in", "-", "newline-delimited elements, - for stdin")
+	modeName := fs.String("mode", "", "expected mode of the filter, checked if given")
+	out := fs.String("out", "", "file to write the combined ciphertexts to")
+	workers := fs.Int("workers", runtime.NumCPU(), "maximum concurrent combinations")
+	hf := addHasherKeyFlag(fs)
+	if e := fs.Parse(args); e != nil {
+		return e
+	}
+	if *filter == "" || *out == "" {
+		return errors.New("evaluate: -filter and -out are required")
+	}
+	if *workers < 1 {
+		return errors.New("evaluate: -workers must be at least 1")
+	}
+
+	data, e := ioutil.ReadFile(*filter)
+	if e != nil {
+		return e
+	}
+	hkey, e := hf.key()
+	if e != nil {
+		return e
+	}
+	eb, e := encbf.NewPublicKeyed(data, hkey)
+	if e != nil {
+		return fmt.Errorf("%s: %v", *filter, e)
+	}
+	if *modeName != "" {
+		mode, e := parseMode(*modeName)
+		if e != nil {
+			return e
+		}
+		if mode != eb.Mode() {
+			return fmt.Errorf("evaluate: filter was encrypted for a different mode")
+		}
+	}
+
+	r, e := open(*in, stdin)
+	if e != nil {
+		return e
+	}
+	defer r.Close()
+	keys, e := readLines(r)
+	if e != nil {
+		return e
+	}
+	rs, e := eb.CheckBatch(context.Background(), keys, *workers)
+	if e != nil {
+		return e
+	}
+	ca, e := rs.Ciphertexts()
+	if e != nil {
+		return e
+	}
+
+	return ioutil.WriteFile(*out, encbf.MarshalResults(rs.Mode, ca), 0644)
+}
+
+// decrypt prints the union or intersection, one element per line, or the
+// cardinalities in ca mode
+func decrypt(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("decrypt", flag.ContinueOnError)
+	key := fs.String("key", "", "private key written by keygen")
+	in := fs.String("in", "", "combined ciphertexts written by evaluate")
+	modeName := fs.String("mode", "psi", "mode the filter was encrypted for")
+	set := fs.String("set", "", "elements of the Owner's filter, needed for psu and ca")
+	workers := fs.Int("workers", runtime.NumCPU(), "maximum concurrent decryptions")
+	if e := fs.Parse(args); e != nil {
+		return e
+	}
+	mode, e := parseMode(*modeName)
+	if e != nil {
+		return e
+	}
+	if *in == "" || (*set == "" && mode != encbf.ModePSI) {
+		return errors.New("decrypt: -in is required, and -set for psu and ca")
+	}
+	if *workers < 1 {
+		return errors.New("decrypt: -workers must be at least 1")
+	}
+	owner, e := readOwner(*key)
+	if e != nil {
+		return e
+	}
+
+	data, e := ioutil.ReadFile(*in)
+	if e != nil {
+		return e
+	}
+	rmode, ca, e := encbf.UnmarshalResults(owner.Public(), data)
+	if e != nil {
+		return fmt.Errorf("%s: %v", *in, e)
+	}
+	if rmode != mode {
+		return fmt.Errorf("decrypt: results were computed for a different mode")
+	}
+	var elems [][]byte
+	if *set != "" {
+		r, e := open(*set, stdin)
+		if e != nil {
+			return e
+		}
+		defer r.Close()
+		if elems, e = readLines(r); e != nil {
+			return e
+		}
+	}
+
+	res, e := protocol.Interpret(owner, ca, elems, mode, *workers)
+	if e != nil {
+		return e
+	}
+	w := bufio.NewWriter(stdout)
+	if mode == encbf.ModeCA {
+		fmt.Fprintf(w, "intersection: %d\nunion: %d\n", res.Cardinality, res.Union)
+	}
+	for _, v := range res.Elements {
+		fmt.Fprintf(w, "%s\n", v)
+	}
+
+	return w.Flush()
+}
+
+func readOwner(file string) (*encbf.Owner, error) {
+	if file == "" {
+		return nil, errors.New("a key file is required")
+	}
+	data, e := ioutil.ReadFile(file)
+	if e != nil {
+		return nil, e
+	}
+	owner, e := encbf.NewOwnerFromKey(data)
+	if e != nil {
+		return nil, fmt.Errorf("%s: %v", file, e)
+	}
+
+	return owner, nil
+}
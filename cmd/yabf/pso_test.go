@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPSO(t *testing.T) {
+	dir, e := ioutil.TempDir("", "yabf")
+	if e != nil {
+		log.Fatalln(e)
+	}
+	defer os.RemoveAll(dir)
+
+	set := writeTemp(dir, "set.txt", "apple\nbanana\ncherry\n")
+	other := writeTemp(dir, "other.txt", "cherry\ndate\n")
+	sbf := filepath.Join(dir, "set.bf")
+	runOut("", "build", "-n", "10", "-in", set, "-out", sbf)
+
+	want := map[string]string{
+		"psi": "cherry\n",
+		"psu": "apple\nbanana\ncherry\ndate\n",
+		"ca":  "intersection: 1\nunion: 4\n",
+	}
+	for _, scheme := range []string{"paillier", "elgamal"} {
+		key := filepath.Join(dir, scheme+".key")
+		runOut("", "keygen", "-scheme", scheme, "-bits", "512", "-out", key)
+
+		for _, mode := range []string{"psi", "psu", "ca"} {
+			if scheme == "elgamal" && mode != "ca" {
+				continue
+			}
+			ebf := filepath.Join(dir, mode+".ebf")
+			results := filepath.Join(dir, mode+".bin")
+			runOut("", "encrypt", "-key", key, "-filter", sbf, "-mode", mode, "-out", ebf)
+			if e := run([]string{"evaluate", "-filter", ebf, "-in", other, "-mode", "zzz", "-out", results}, nil, ioutil.Discard); e == nil {
+				log.Fatalln("Unknown mode should be rejected")
+			}
+			runOut("", "evaluate", "-filter", ebf, "-in", other, "-mode", mode, "-out", results)
+
+			for _, args := range [][]string{
+				{"encrypt", "-key", key, "-filter", sbf, "-mode", mode, "-out", ebf, "-workers", "0"},
+				{"evaluate", "-filter", ebf, "-in", other, "-out", results, "-workers", "0"},
+				{"decrypt", "-key", key, "-in", results, "-mode", mode, "-set", set, "-workers", "-1"},
+			} {
+				if e := run(args, nil, ioutil.Discard); e == nil {
+					log.Fatalln("Fewer than one worker should be rejected by", args[0])
+				}
+			}
+
+			got := runOut("", "decrypt", "-key", key, "-in", results, "-mode", mode, "-set", set)
+			if got != want[mode] {
+				log.Fatalln("Unexpected", scheme, mode, "output:", strings.TrimSpace(got))
+			}
+			wrong := map[string]string{"psi": "psu", "psu": "ca", "ca": "psi"}[mode]
+			if e := run([]string{"decrypt", "-key", key, "-in", results, "-mode", wrong, "-set", set}, nil, ioutil.Discard); e == nil {
+				log.Fatalln("Results for", mode, "should be rejected in", wrong, "mode")
+			}
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package concurrent
+
+import (
+	"github.com/alxdavids/bloom-filter"
+	"github.com/alxdavids/bloom-filter/standard"
+	"hash"
+	"log"
+	"sync"
+	"sync/atomic"
+	"xojoc.pw/bitset"
+)
+
+// ConcurrentBloom is a Bloom filter that is safe for concurrent use. It sets
+// the same bits as a StandardBloom with the same parameters and hasher. Bits
+// are kept in words updated with atomic operations, and every call uses its
+// own index buffer and a hasher from a pool, so Add and Check never wait on
+// each other. Only a custom hasher given to SetHasher is shared under a lock.
+//
+// Reset and the hasher setters replace the filter's state atomically. Adds
+// running at the same time may land in the replaced state and be lost.
+type ConcurrentBloom struct {
+	L   uint                  // Length of Bloom filter
+	k   uint                  // Number of hash functions
+	eps float64               // false-positive probability
+	n   uint                  // predicted size of set
+	c   atomic.Uint64         // count of elements in the Bloom filter
+	st  atomic.Pointer[state] // bits and hasher
+	mu  sync.Mutex            // serializes Reset and the hasher setters
+}
+
+type state struct {
+	words []atomic.Uint64 // complete array of bits
+	pool  *sync.Pool      // hashers for registered ids
+	hs    bloom.Hasher    // hasher, used directly only if custom
+	hmu   sync.Mutex      // guards a custom hs
+}
+
+var _ bloom.Bloom = (*ConcurrentBloom)(nil)
+
+func New(n uint, eps float64) bloom.Bloom {
+	this := &ConcurrentBloom{
+		k:   bloom.K(eps),
+		L:   bloom.L(eps, n),
+		eps: eps,
+		n:   n,
+	}
+	this.st.Store(this.newState(bloom.DefaultHasher()))
+
+	return this
+}
+
+func (this *ConcurrentBloom) newState(hs bloom.Hasher) *state {
+	st := &state{
+		words: make([]atomic.Uint64, (this.L+63)/64),
+		hs:    hs,
+	}
+	if hs.HasherID() != bloom.HasherCustom {
+		st.pool = &sync.Pool{New: func() interface{} {
+			c := hs.CloneHasher()
+			return c.Hash()
+		}}
+	}
+
+	return st
+}
+
+// SetHasher replaces the hash function and clears the filter. Calls hashing
+// with h are serialized since a hash.Hash cannot be shared; prefer UseHasher.
+func (this *ConcurrentBloom) SetHasher(h hash.Hash) {
+	var hs bloom.Hasher
+	hs.SetHasher(h)
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.st.Store(this.newState(hs))
+	this.c.Store(0)
+}
+
+// UseHasher selects the registered hash function id and clears the filter,
+// key is required by keyed hashers and must be nil otherwise
+func (this *ConcurrentBloom) UseHasher(id bloom.HasherID, key []byte) error {
+	var hs bloom.Hasher
+	if e := hs.UseHasher(id, key); e != nil {
+		return e
+	}
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.st.Store(this.newState(hs))
+	this.c.Store(0)
+
+	return nil
+}
+
+func (this *ConcurrentBloom) Add(key []byte) bloom.Bloom {
+	st := this.st.Load()
+	for _, v := range this.indices(st, key) {
+		st.words[v/64].Or(1 << (v % 64))
+	}
+
+	if uint(this.c.Add(1)) > this.n {
+		log.Println("Adding a greater number of elements than are expected. Expect failure.")
+	}
+
+	return this
+}
+
+func (this *ConcurrentBloom) Check(key []byte) bool {
+	st := this.st.Load()
+	for _, v := range this.indices(st, key) {
+		if st.words[v/64].Load()&(1<<(v%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Reset clears the filter, keeping its hasher
+func (this *ConcurrentBloom) Reset() {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	st := this.st.Load()
+	this.st.Store(this.newState(st.hs.CloneHasher()))
+	this.c.Store(0)
+}
+
+// Count returns the number of Add calls since the last Reset
+func (this *ConcurrentBloom) Count() uint {
+	return uint(this.c.Load())
+}
+
+// ToStandard returns a snapshot of the filter as a StandardBloom, for example
+// to serialize it or feed encbf.New
+func (this *ConcurrentBloom) ToStandard() *standard.StandardBloom {
+	st := this.st.Load()
+	bf := &bitset.BitSet{}
+	for i := range st.words {
+		w := st.words[i].Load()
+		for j := 0; w != 0; j++ {
+			if w&1 == 1 {
+				bf.Set(i*64 + j)
+			}
+			w >>= 1
+		}
+	}
+
+	sbf := standard.FromBitset(this.n, this.eps, bf, this.Count())
+	sbf.Hasher = st.hs.CloneHasher()
+
+	return sbf
+}
+
+// indices returns the k bit indices of key in a buffer owned by the caller
+func (this *ConcurrentBloom) indices(st *state, key []byte) []uint {
+	var s []byte
+	if st.pool == nil {
+		st.hmu.Lock()
+		s = st.hs.Sum(key)
+		st.hmu.Unlock()
+	} else {
+		h := st.pool.Get().(hash.Hash)
+		h.Reset()
+		if _, e := h.Write(key); e != nil {
+			log.Println(e)
+		}
+		s = h.Sum(nil)
+		st.pool.Put(h)
+	}
+
+	bs := make([]uint, this.k)
+	bloom.Indices(s, this.L, bs)
+
+	return bs
+}
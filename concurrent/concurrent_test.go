@@ -0,0 +1,73 @@
+package concurrent
+
+import (
+	"github.com/alxdavids/bloom-filter/standard"
+	"github.com/reusee/mmh3"
+	"log"
+	"math/big"
+	"sync"
+	"testing"
+)
+
+var (
+	n       uint = 4000
+	eps          = 0.001
+	workers      = 8
+)
+
+// Run with -race: writers and readers hammer the filter in parallel, and the
+// result must match a StandardBloom built sequentially
+func TestConcurrent(t *testing.T) {
+	cbf := New(n, eps).(*ConcurrentBloom)
+	hammer(cbf)
+
+	sbf := standard.New(n, eps).(*standard.StandardBloom)
+	for i := int64(0); i < int64(n); i++ {
+		sbf.Add(big.NewInt(i).Bytes())
+	}
+	_, _, _, _, _, want := sbf.GetParams()
+	_, _, _, _, _, got := cbf.ToStandard().GetParams()
+	if !got.Equal(want) || cbf.Count() != n {
+		log.Fatalln("Concurrent filter differs from the sequential one")
+	}
+
+	cbf.Reset()
+	if cbf.Count() != 0 || cbf.Check(big.NewInt(0).Bytes()) {
+		log.Fatalln("Reset did not clear the filter")
+	}
+}
+
+func TestConcurrentCustomHasher(t *testing.T) {
+	cbf := New(n, eps).(*ConcurrentBloom)
+	cbf.SetHasher(mmh3.New128())
+	hammer(cbf)
+}
+
+// hammer adds [0, n) from half the workers while the other half check keys,
+// then checks that every key is present
+func hammer(cbf *ConcurrentBloom) {
+	var wg sync.WaitGroup
+	per := int64(n) / int64(workers/2)
+	for w := 0; w < workers/2; w++ {
+		wg.Add(2)
+		go func(start int64) {
+			defer wg.Done()
+			for i := start; i < start+per; i++ {
+				cbf.Add(big.NewInt(i).Bytes())
+			}
+		}(int64(w) * per)
+		go func(start int64) {
+			defer wg.Done()
+			for i := start; i < start+per; i++ {
+				cbf.Check(big.NewInt(i).Bytes())
+			}
+		}(int64(w) * per)
+	}
+	wg.Wait()
+
+	for i := int64(0); i < int64(n); i++ {
+		if !cbf.Check(big.NewInt(i).Bytes()) {
+			log.Fatalln("Key not found in concurrent Bloom filter", i)
+		}
+	}
+}
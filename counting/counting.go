@@ -0,0 +1,157 @@
+package counting
+
+import (
+	"errors"
+	"github.com/alxdavids/bloom-filter"
+	"github.com/alxdavids/bloom-filter/standard"
+	"log"
+	"xojoc.pw/bitset"
+)
+
+var ErrWidth = errors.New("counting: counter width must be 4, 8 or 16 bits")
+
+// CountingBloom replaces each bit of a StandardBloom with a small counter so
+// that elements can be removed. Counters saturate at their maximum value and
+// are never decremented afterwards, which keeps false negatives impossible at
+// the cost of a saturated counter never returning to zero.
+type CountingBloom struct {
+	bloom.Hasher          // hash function used for query and storage
+	L            uint     // Length of Bloom filter
+	k            uint     // Number of hash functions
+	eps          float64  // false-positive probability
+	n            uint     // predicted size of set
+	w            uint     // width of each counter in bits
+	cs           []uint64 // counters packed into words
+	bs           []uint   // array of k bits from hash functions
+	c            uint     // count of elements in the Bloom filter
+}
+
+var _ bloom.Bloom = (*CountingBloom)(nil)
+
+func New(n uint, eps float64, width uint) (bloom.Bloom, error) {
+	if width != 4 && width != 8 && width != 16 {
+		return nil, ErrWidth
+	}
+
+	var (
+		k = bloom.K(eps)
+		L = bloom.L(eps, n)
+	)
+
+	return &CountingBloom{
+		Hasher: bloom.DefaultHasher(),
+		k:      k,
+		L:      L,
+		eps:    eps,
+		n:      n,
+		w:      width,
+		cs:     make([]uint64, (L*width+63)/64),
+		bs:     make([]uint, uint(k)),
+	}, nil
+}
+
+func (this *CountingBloom) Add(key []byte) bloom.Bloom {
+	this.setBitset(key)
+	for _, v := range this.bs[:this.k] {
+		if c := this.get(v); c < this.max() {
+			this.set(v, c+1)
+		}
+	}
+
+	this.c++
+	if this.c > this.n {
+		log.Println("Adding a greater number of elements than are expected. Expect failure.")
+	}
+
+	return this
+}
+
+// Remove decrements the counters of key. It returns false and leaves the
+// filter unchanged if key is not present. Removing a key that was never
+// added but is a false positive will introduce false negatives.
+func (this *CountingBloom) Remove(key []byte) bool {
+	if !this.Check(key) {
+		return false
+	}
+
+	// Check has already populated bs
+	for _, v := range this.bs[:this.k] {
+		if c := this.get(v); c < this.max() {
+			this.set(v, c-1)
+		}
+	}
+
+	if this.c > 0 {
+		this.c--
+	}
+
+	return true
+}
+
+func (this *CountingBloom) Check(key []byte) bool {
+	this.setBitset(key)
+	for _, v := range this.bs[:this.k] {
+		if this.get(v) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (this *CountingBloom) Reset() {
+	this.k = bloom.K(this.eps)
+	this.L = bloom.L(this.eps, this.n)
+	this.cs = make([]uint64, (this.L*this.w+63)/64)
+	this.bs = make([]uint, this.k)
+	this.c = 0
+	this.Hasher = bloom.DefaultHasher()
+}
+
+// Saturated reports the number of counters stuck at their maximum value
+func (this *CountingBloom) Saturated() uint {
+	s := uint(0)
+	for i := uint(0); i < this.L; i++ {
+		if this.get(i) == this.max() {
+			s++
+		}
+	}
+
+	return s
+}
+
+// ToStandard returns a StandardBloom with a bit set wherever a counter is
+// non-zero, for example to feed encbf.New. The hasher is carried over.
+func (this *CountingBloom) ToStandard() *standard.StandardBloom {
+	bf := &bitset.BitSet{}
+	for i := uint(0); i < this.L; i++ {
+		if this.get(i) != 0 {
+			bf.Set(int(i))
+		}
+	}
+
+	sbf := standard.FromBitset(this.n, this.eps, bf, this.c)
+	sbf.Hasher = this.CloneHasher()
+
+	return sbf
+}
+
+func (this *CountingBloom) max() uint64 {
+	return 1<<this.w - 1
+}
+
+func (this *CountingBloom) get(i uint) uint64 {
+	off := i * this.w
+	return (this.cs[off/64] >> (off % 64)) & this.max()
+}
+
+func (this *CountingBloom) set(i uint, v uint64) {
+	off := i * this.w
+	this.cs[off/64] &^= this.max() << (off % 64)
+	this.cs[off/64] |= v << (off % 64)
+}
+
+func (this *CountingBloom) setBitset(key []byte) {
+	s := this.Sum(key)
+	bloom.Indices(s, this.L, this.bs[:this.k])
+}
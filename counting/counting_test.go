@@ -0,0 +1,72 @@
+package counting
+
+import (
+	"log"
+	"math/big"
+	"testing"
+)
+
+var (
+	n   uint = 100
+	eps      = 0.0001
+)
+
+func TestCounting(t *testing.T) {
+	for _, w := range []uint{4, 8, 16} {
+		b, e := New(n, eps, w)
+		if e != nil {
+			log.Fatalln(e)
+		}
+		cbf := b.(*CountingBloom)
+
+		for i := int64(0); i < int64(n); i++ {
+			cbf.Add(big.NewInt(i).Bytes())
+		}
+		for i := int64(0); i < int64(n); i += 2 {
+			if !cbf.Remove(big.NewInt(i).Bytes()) {
+				log.Fatalln("Failed to remove key", i)
+			}
+		}
+		for i := int64(0); i < int64(n); i++ {
+			if i%2 == 1 && !cbf.Check(big.NewInt(i).Bytes()) {
+				log.Fatalln("Key not found in counting Bloom filter", i)
+			}
+		}
+
+		if cbf.Remove(big.NewInt(int64(n) + 1000).Bytes()) {
+			log.Fatalln("Removed a key that was never added")
+		}
+
+		sbf := cbf.ToStandard()
+		for i := int64(0); i < 2*int64(n); i++ {
+			key := big.NewInt(i).Bytes()
+			if sbf.Check(key) != cbf.Check(key) {
+				log.Fatalln("Standard view disagrees with counting Bloom filter")
+			}
+		}
+	}
+
+	if _, e := New(n, eps, 5); e != ErrWidth {
+		log.Fatalln("Expected width error, got", e)
+	}
+}
+
+func TestSaturation(t *testing.T) {
+	b, _ := New(n, eps, 4)
+	cbf := b.(*CountingBloom)
+	key := []byte("saturate")
+	for i := 0; i < 20; i++ {
+		cbf.Add(key)
+	}
+	if s := cbf.Saturated(); s == 0 || s > cbf.k {
+		log.Fatalln("Expected the counters of the key to saturate")
+	}
+
+	// Saturated counters stick, so the key survives any number of removals
+	for i := 0; i < 20; i++ {
+		cbf.Remove(key)
+	}
+	if !cbf.Check(key) {
+		log.Fatalln("Saturated key should remain in the filter")
+	}
+}
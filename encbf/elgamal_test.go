@@ -0,0 +1,96 @@
+package encbf
+
+import (
+	"context"
+	"crypto/rand"
+	"github.com/alxdavids/bloom-filter/standard"
+	"log"
+	"math/big"
+	"testing"
+)
+
+func TestElGamal(t *testing.T) {
+	testScheme(&ElGamal{})
+
+	s, e := (&ElGamal{}).KeyGen(rand.Reader, 0)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	c0, _ := s.Encrypt(big.NewInt(0))
+	c1, _ := s.Encrypt(big.NewInt(1))
+	if z, e := s.(ZeroTester).IsZero(s.ScalarMul(c0, big.NewInt(12345))); e != nil || !z {
+		log.Fatalln("Should be encryption of zero [elgamal]")
+	}
+	r, _ := randPlaintext(s)
+	if z, e := s.(ZeroTester).IsZero(s.ScalarMul(c1, r)); e != nil || z {
+		log.Fatalln("Shouldn't be encryption of zero [elgamal]")
+	}
+}
+
+func TestElGamalCardinality(t *testing.T) {
+	sbf := standard.New(n, eps)
+	for i := 1; i <= int(n); i++ {
+		sbf = sbf.Add(big.NewInt(int64(i)).Bytes())
+	}
+
+	owner, e := NewOwnerWithScheme(&ElGamal{}, 0)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	if _, e := owner.Encrypt(sbf.(*standard.StandardBloom), ModePSI, maxConc); e != ErrMode {
+		log.Fatalln("ElGamal should only support ModeCA, got", e)
+	}
+	eb, e := owner.Encrypt(sbf.(*standard.StandardBloom), ModeCA, maxConc)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	data, e := eb.MarshalBinary()
+	if e != nil {
+		log.Fatalln(e)
+	}
+	evaluator, e := NewPublic(data)
+	if e != nil {
+		log.Fatalln(e)
+	}
+
+	// The mode byte follows magic, version and hasher
+	bad := append([]byte(nil), data...)
+	bad[6] = ModePSI
+	if _, e := NewPublic(bad); e != ErrMode {
+		log.Fatalln("ElGamal filters should only load in ModeCA, got", e)
+	}
+	// Undecodable points are not read as the identity
+	c := eb.ebf[0]
+	eb.ebf[0] = big.NewInt(1)
+	if bad, e = eb.MarshalBinary(); e != nil {
+		log.Fatalln(e)
+	}
+	eb.ebf[0] = c
+	if _, e := NewPublic(bad); e != ErrInvalidEncoding {
+		log.Fatalln("Expected encoding error for an invalid ciphertext, got", e)
+	}
+
+	keys := [][]byte{}
+	for i := int(n)/2 + 1; i <= int(n)+int(n)/2; i++ {
+		keys = append(keys, big.NewInt(int64(i)).Bytes())
+	}
+	rs, e := evaluator.CheckBatch(context.Background(), keys, maxConc)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	ca, e := rs.Ciphertexts()
+	if e != nil {
+		log.Fatalln(e)
+	}
+	if _, _, e := UnmarshalResults(owner.Public(), MarshalResults(ModeCA, [][]*big.Int{{big.NewInt(1)}})); e != ErrInvalidEncoding {
+		log.Fatalln("Expected encoding error for an invalid ciphertext, got", e)
+	}
+
+	inter, union, e := owner.Cardinality(ca, int(n), maxConc)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	if inter != int(n)/2 || union != int(n)+int(n)/2 {
+		log.Fatalln("Wrong cardinalities:", inter, union)
+	}
+}
@@ -1,122 +1,86 @@
 package encbf
 
 import (
+	"context"
 	"crypto/rand"
-	"encoding/binary"
 	"github.com/alxdavids/bloom-filter"
-	"github.com/alxdavids/bloom-filter/standard"
-	"github.com/mcornejo/go-go-gadget-paillier"
-	"github.com/reusee/mmh3"
+	"github.com/alxdavids/bloom-filter/partitioned"
 	"hash"
 	"log"
 	"math/big"
 	"sync"
-	"time"
 	"xojoc.pw/bitset"
 )
 
+// EncBloom is the evaluating side of the protocol: it holds the public key
+// and the encrypted filter, and combines ciphertexts for queried elements.
 type EncBloom struct {
-	h     hash.Hash               // hash function used for query and storage
-	L     uint                    // Length of Bloom filter
-	k     uint                    // Number of hash functions
-	eps   float64                 // false-positive probability
-	n     uint                    // predicted size of set
-	ebf   []*big.Int              // complete array of encrypted bits
-	bf    *bitset.BitSet          // original bits (for testing)
-	bs    []uint                  // array of k bits from hash functions
-	m     uint                    // size of second set
-	ca    [][]*big.Int            // array of combined ciphertexts
-	tmpCa map[string]([]*big.Int) // temp array for holding ciphertexts for combining
-	pub   *paillier.PublicKey     // public key for encryption
-	priv  *paillier.PrivateKey    // private key for decryption
-	mode  int                     // mode for performing PSO (0 = PSU, 1 = PSI, 2 = PSI/PSU-CA)
+	bloom.Hasher                // hash function used for query and storage
+	L            uint           // Length of Bloom filter
+	k            uint           // Number of hash functions
+	eps          float64        // false-positive probability
+	n            uint           // predicted size of set
+	ebf          []*big.Int     // complete array of encrypted bits
+	bf           *bitset.BitSet // original bits (for testing)
+	bs           []uint         // array of k bits from hash functions
+	m            uint           // size of second set
+	scheme       Scheme         // encryption scheme, public key only
+	owner        *Owner         // key holder, only set when built by New
+	mode         int            // mode for performing PSO (ModePSU, ModePSI or ModeCA)
+	layout       bloom.Layout   // index layout of the plaintext filter
 }
 
 var _ bloom.Bloom = (*EncBloom)(nil)
 
-func New(sbf *standard.StandardBloom, keySize, mode, maxConcurrentGoroutines int) bloom.Bloom {
-	h, L, k, n, eps, sbfa := sbf.GetParams()
+// Modes for performing PSO
+const (
+	ModePSU = 0 // private set union
+	ModePSI = 1 // private set intersection
+	ModeCA  = 2 // cardinality of intersection/union
+)
 
-	keyTime := time.Now()
-	priv, e := paillier.GenerateKey(rand.Reader, keySize)
-	if e != nil {
-		log.Fatalln(e)
-	}
-	pub := &priv.PublicKey
-	log.Printf("Key time: %v", time.Since(keyTime).Seconds())
+// Combined holds the combined ciphertexts for one queried element
+type Combined struct {
+	Key         []byte
+	Ciphertexts []*big.Int
+}
 
-	// construct ciphertexts for bloom filter
-	ebf := make([]*big.Int, uint(L))
+// ResultSet is the outcome of CheckBatch: one Combined per queried element,
+// in input order with duplicates preserved
+type ResultSet struct {
+	Mode    int
+	Results []Combined
+}
 
-	// Use this channel for limiting goroutines
-	concurrentGoroutines := make(chan struct{}, maxConcurrentGoroutines)
-	for i := 0; i < maxConcurrentGoroutines; i++ {
-		concurrentGoroutines <- struct{}{}
-	}
-	done := make(chan bool)
-	waitForAllJobs := make(chan bool)
-
-	// Collect all the jobs, and since the job is finished, we can
-	// release another spot for a goroutine.
-	go func() {
-		for i := uint(0); i < L; i++ {
-			<-done
-			// Say that another goroutine can now start.
-			concurrentGoroutines <- struct{}{}
-		}
-		// We have collected all the jobs, the program
-		// can now terminate
-		waitForAllJobs <- true
-	}()
-
-	// Start the encryption process with limited goroutines
-	encTime := time.Now()
-	for i := uint(0); i < L; i++ {
-		// Wait till we're allowed to go
-		<-concurrentGoroutines
-
-		go func(i uint, ebf []*big.Int, sbfa *bitset.BitSet) {
-			var m *big.Int
-			// Remember that we operate over an encrypted Bloom filter
-			if sbfa.Get(int(i)) {
-				m = big.NewInt(0)
-			} else {
-				m = big.NewInt(1)
-			}
-			c, e := paillier.Encrypt(pub, m.Bytes())
-			if e != nil {
-				log.Fatalln(e)
-			}
+// Filter is a plaintext Bloom filter that can be encrypted, implemented by
+// standard.StandardBloom and partitioned.PartitionedBloom
+type Filter interface {
+	GetParams() (hash.Hash, uint, uint, uint, float64, *bitset.BitSet)
+	CloneHasher() bloom.Hasher
+	Layout() bloom.Layout
+}
 
-			cInt := new(big.Int).SetBytes(c)
-			ebf[i] = cInt
+type query struct {
+	key  []byte     // queried element
+	comb []*big.Int // encrypted bits of the filter at the element's indices
+}
 
-			done <- true
-		}(i, ebf, sbfa)
+// New runs both roles in one process: it generates a keypair, encrypts sbf
+// and keeps the Owner so that Decrypt can be called on the results. Use
+// NewOwner and Owner.Encrypt when the parties are deployed separately.
+func New(sbf Filter, keySize, mode, maxConcurrentGoroutines int) (bloom.Bloom, error) {
+	owner, e := NewOwner(keySize)
+	if e != nil {
+		return nil, e
 	}
-	<-waitForAllJobs
-	log.Printf("Enc time: %v", time.Since(encTime).Seconds())
-
-	return &EncBloom{
-		h:     h,
-		k:     k,
-		L:     L,
-		eps:   eps,
-		n:     n,
-		ebf:   ebf,
-		bf:    sbfa,
-		bs:    make([]uint, uint(k)),
-		m:     n,
-		ca:    [][]*big.Int{},
-		tmpCa: map[string][]*big.Int{},
-		pub:   pub,
-		priv:  priv,
-		mode:  mode,
+	eb, e := owner.EncryptFast(sbf, mode, maxConcurrentGoroutines)
+	if e != nil {
+		return nil, e
 	}
-}
+	_, _, _, _, _, eb.bf = sbf.GetParams()
+	eb.owner = owner
 
-func (this *EncBloom) SetHasher(h hash.Hash) {
-	this.h = h
+	return eb, nil
 }
 
 func (this *EncBloom) Add(key []byte) bloom.Bloom {
@@ -124,185 +88,278 @@ func (this *EncBloom) Add(key []byte) bloom.Bloom {
 	return this
 }
 
-// This function is much different to the one in Standard Bloom
-// We also populate an array of ciphertexts
+// Membership cannot be decided without the private key, so Check always
+// returns false. Use CheckBatch to combine ciphertexts for the Owner.
 func (this *EncBloom) Check(key []byte) bool {
-	this.setBitset(key)
-	combArr := make([]*big.Int, this.k)
-	for i, v := range this.bs[:this.k] {
-		combArr[i] = this.ebf[v]
+	log.Println("Checking elements in the encrypted setting is not permitted. Use CheckBatch.")
+	return false
+}
+
+// CheckBatch homomorphically combines the ciphertexts of the filter for each
+// of keys, using at most maxConcurrentGoroutines workers. The first failure
+// is returned as a *QueryError naming the element that could not be
+// combined, and cancelling ctx stops the remaining work.
+func (this *EncBloom) CheckBatch(ctx context.Context, keys [][]byte, maxConcurrentGoroutines int) (*ResultSet, error) {
+	if maxConcurrentGoroutines < 1 {
+		return nil, ErrConcurrency
+	}
+	// Indices are derived sequentially since the hasher is shared
+	queries := make([]query, len(keys))
+	for i, key := range keys {
+		this.setBitset(key)
+		combArr := make([]*big.Int, this.k)
+		for j, v := range this.bs[:this.k] {
+			combArr[j] = this.ebf[v]
+		}
+		queries[i] = query{key: append([]byte(nil), key...), comb: combArr}
 	}
 
-	this.tmpCa[string(key)] = combArr
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		err  error
+		once sync.Once
+	)
+	fail := func(e error) {
+		once.Do(func() { err = e })
+		cancel()
+	}
 
-	// var arr []*big.Int
-	// if this.mode == 0 {
-	// 	arr = this.compUnionPair(combArr, key)
-	// } else if this.mode == 1 {
-	// 	arr = this.compInterPair(combArr, key)
-	// } else if this.mode == 2 {
-	// 	arr = this.compCaPair(combArr)
-	// }
-	// this.ca = append(this.ca, arr)
+	// Use this channel for limiting goroutines
+	concurrentGoroutines := make(chan struct{}, maxConcurrentGoroutines)
+	for i := 0; i < maxConcurrentGoroutines; i++ {
+		concurrentGoroutines <- struct{}{}
+	}
 
-	return true
-}
+	rs := &ResultSet{Mode: this.mode, Results: make([]Combined, len(queries))}
+	for i, q := range queries {
+		// Wait till we're allowed to go
+		select {
+		case <-ctx.Done():
+		case <-concurrentGoroutines:
+		}
+		if e := ctx.Err(); e != nil {
+			fail(e)
+			break
+		}
 
-// Homomorphically combine ciphertexts
-func (this *EncBloom) HomCombine() {
-	var wg sync.WaitGroup
-	wg.Add(len(this.tmpCa))
-	for key, v := range this.tmpCa {
-		go func(key string, v []*big.Int) {
-			defer wg.Done()
-			var arr []*big.Int
-			if this.mode == 0 {
-				arr = this.compUnionPair(v, []byte(key))
-			} else if this.mode == 1 {
-				arr = this.compInterPair(v, []byte(key))
-			} else if this.mode == 2 {
-				arr = this.compCaPair(v)
+		wg.Add(1)
+		go func(i int, q query) {
+			defer func() {
+				concurrentGoroutines <- struct{}{}
+				wg.Done()
+			}()
+			if ctx.Err() != nil {
+				return
 			}
-			this.ca = append(this.ca, arr)
-		}(key, v)
+
+			arr, e := this.combine(q)
+			if e != nil {
+				fail(&QueryError{Key: q.key, Err: e})
+				return
+			}
+			rs.Results[i] = Combined{Key: q.key, Ciphertexts: arr}
+		}(i, q)
 	}
 	wg.Wait()
+	if e := ctx.Err(); e != nil {
+		fail(e)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return rs, nil
+}
+
+func (this *EncBloom) combine(q query) ([]*big.Int, error) {
+	if this.mode == ModePSU {
+		return this.compUnionPair(q.comb, q.key)
+	} else if this.mode == ModePSI {
+		return this.compInterPair(q.comb, q.key)
+	} else if this.mode == ModeCA {
+		return this.compCaPair(q.comb)
+	}
+
+	return nil, ErrMode
 }
 
 func (this *EncBloom) Reset() {
 	this.k = bloom.K(this.eps)
 	this.L = bloom.L(this.eps, this.n)
+	if this.layout == bloom.LayoutPartitioned {
+		this.L = partitioned.L(this.eps, this.n)
+	}
 	this.ebf = make([]*big.Int, this.L)
 	this.bs = make([]uint, this.k)
-	this.h = mmh3.New128()
-	this.ca = [][]*big.Int{}
-	this.tmpCa = map[string][]*big.Int{}
+	this.Hasher = bloom.DefaultHasher()
 }
 
-func (this *EncBloom) ResetForTesting() {
-	this.ca = [][]*big.Int{}
-	this.tmpCa = map[string][]*big.Int{}
+// Decrypt the results of CheckBatch when both roles run in one process
+func (this *EncBloom) Decrypt(rs *ResultSet, maxConcurrentGoroutines int) ([]Plaintext, error) {
+	if this.owner == nil {
+		return nil, ErrNoPrivateKey
+	}
+
+	return this.owner.Decrypt(rs.ciphertexts(), maxConcurrentGoroutines)
 }
 
-// Decrypt method for use when interacting with EBF
-func (this *EncBloom) Decrypt() [][][]byte {
-	ptxts := make([][][]byte, len(this.ca))
-	for i, v := range this.ca {
-		m0, e := paillier.Decrypt(this.priv, v[0].Bytes())
-		if e != nil {
-			log.Fatalln(e)
-		}
+// Cardinality of the intersection and union when both roles run in one
+// process, setSize is the number of elements in the encrypted filter
+func (this *EncBloom) Cardinality(rs *ResultSet, setSize, maxConcurrentGoroutines int) (int, int, error) {
+	if this.owner == nil {
+		return 0, 0, ErrNoPrivateKey
+	}
+	ca, e := rs.Ciphertexts()
+	if e != nil {
+		return 0, 0, e
+	}
 
-		var m1 []byte
-		if len(v) > 1 {
-			m1, e = paillier.Decrypt(this.priv, v[1].Bytes())
-			if e != nil {
-				log.Fatalln(e)
-			}
-		}
+	return this.owner.Cardinality(ca, setSize, maxConcurrentGoroutines)
+}
+
+// Ciphertexts returns the combined ciphertexts, ready to be returned to the
+// Owner. In ModeCA they are shuffled so that the Owner learns only how many
+// elements matched and not which ones.
+func (rs *ResultSet) Ciphertexts() ([][]*big.Int, error) {
+	if rs.Mode == ModeCA {
+		return shuffle(rs.ciphertexts())
+	}
+
+	return rs.ciphertexts(), nil
+}
 
-		ptxts[i] = [][]byte{m0, m1}
+func (rs *ResultSet) ciphertexts() [][]*big.Int {
+	ca := make([][]*big.Int, len(rs.Results))
+	for i, v := range rs.Results {
+		ca[i] = v.Ciphertexts
 	}
 
-	return ptxts
+	return ca
+}
+
+// Scheme returns the public-only encryption scheme of the filter
+func (this *EncBloom) Scheme() Scheme {
+	return this.scheme
 }
 
-func (this *EncBloom) GetPubKey() *paillier.PublicKey {
-	return this.pub
+func (this *EncBloom) Mode() int {
+	return this.mode
 }
 
 func (this *EncBloom) DumpParams() {
 	log.Printf("L: %v,\n k: %v,\n eps: %v,\n n: %v,\n mode: %v,\n", this.L, this.k, this.eps, this.n, this.mode)
 }
 
-func (this *EncBloom) compUnionPair(combArr []*big.Int, key []byte) []*big.Int {
-	var ciph []byte
+func (this *EncBloom) compUnionPair(combArr []*big.Int, key []byte) ([]*big.Int, error) {
+	k := new(big.Int).SetBytes(key)
+	if this.scheme.PlaintextModulus().Cmp(k) < 1 {
+		return nil, ErrMessageTooLong
+	}
+
+	var ciph *big.Int
 	for i, _ := range combArr {
 		if i == 0 {
-			ciph = combArr[i].Bytes()
+			ciph = combArr[i]
 		}
 
 		if i < len(combArr)-1 {
-			ciph = paillier.AddCipher(this.pub, ciph, combArr[i+1].Bytes())
+			ciph = this.scheme.AddCipher(ciph, combArr[i+1])
 		}
 	}
-	ckey := paillier.Mul(this.pub, ciph, key)
-	c00, e := paillier.Encrypt(this.pub, big.NewInt(0).Bytes())
+	ckey, e := this.scheme.Rerandomize(this.scheme.ScalarMul(ciph, k))
 	if e != nil {
-		log.Fatalln(e)
+		return nil, e
 	}
-	c01, e := paillier.Encrypt(this.pub, big.NewInt(0).Bytes())
+	ciph, e = this.scheme.Rerandomize(ciph)
 	if e != nil {
-		log.Fatalln(e)
+		return nil, e
 	}
-	ckey = paillier.AddCipher(this.pub, ckey, c00)
-	ciph = paillier.AddCipher(this.pub, ciph, c01)
 
-	pair := []*big.Int{new(big.Int).SetBytes(ckey), new(big.Int).SetBytes(ciph)}
-	return pair
+	pair := []*big.Int{ckey, ciph}
+	return pair, nil
 }
 
-func (this *EncBloom) compInterPair(combArr []*big.Int, key []byte) []*big.Int {
-	var ciph []byte
+func (this *EncBloom) compInterPair(combArr []*big.Int, key []byte) ([]*big.Int, error) {
+	var ciph *big.Int
 	for i, _ := range combArr {
 		if i == 0 {
-			ciph = combArr[i].Bytes()
+			ciph = combArr[i]
 		}
 
 		if i < len(combArr)-1 {
-			ciph = paillier.AddCipher(this.pub, ciph, combArr[i+1].Bytes())
+			ciph = this.scheme.AddCipher(ciph, combArr[i+1])
 		}
 	}
-	r, e := rand.Int(rand.Reader, this.pub.N)
+	r, e := randPlaintext(this.scheme)
 	if e != nil {
-		log.Fatalln(e)
+		return nil, e
 	}
-	cr := paillier.Mul(this.pub, ciph, r.Bytes())
-	ckey, e := paillier.Encrypt(this.pub, key)
+	cr := this.scheme.ScalarMul(ciph, r)
+	ckey, e := this.scheme.Encrypt(new(big.Int).SetBytes(key))
 	if e != nil {
-		log.Fatalln(e)
+		return nil, e
+	}
+	ckey = this.scheme.AddCipher(cr, ckey)
+	// The product of the filter's own ciphertexts would let the Owner test
+	// candidate elements
+	ciph, e = this.scheme.Rerandomize(ciph)
+	if e != nil {
+		return nil, e
 	}
-	ckey = paillier.AddCipher(this.pub, cr, ckey)
 
-	pair := []*big.Int{new(big.Int).SetBytes(ckey), new(big.Int).SetBytes(ciph)}
-	return pair
+	pair := []*big.Int{ckey, ciph}
+	return pair, nil
 }
 
-func (this *EncBloom) compCaPair(combArr []*big.Int) []*big.Int {
-	var ciph []byte
+func (this *EncBloom) compCaPair(combArr []*big.Int) ([]*big.Int, error) {
+	var ciph *big.Int
 	for i, _ := range combArr {
 		if i == 0 {
-			ciph = combArr[i].Bytes()
+			ciph = combArr[i]
 		}
 
 		if i < len(combArr)-1 {
-			ciph = paillier.AddCipher(this.pub, ciph, combArr[i+1].Bytes())
+			ciph = this.scheme.AddCipher(ciph, combArr[i+1])
 		}
 	}
-	r, e := rand.Int(rand.Reader, this.pub.N)
+	r, e := randPlaintext(this.scheme)
 	if e != nil {
-		log.Fatalln(e)
+		return nil, e
+	}
+	// Without rerandomization the Owner could recover r and test candidate
+	// elements against the product of its own ciphertexts
+	cr, e := this.scheme.Rerandomize(this.scheme.ScalarMul(ciph, r))
+	if e != nil {
+		return nil, e
 	}
-	cr := paillier.Mul(this.pub, ciph, r.Bytes())
 
-	out := []*big.Int{new(big.Int).SetBytes(cr)}
-	return out
+	out := []*big.Int{cr}
+	return out, nil
 }
 
-func (this *EncBloom) setBitset(key []byte) {
-	this.h.Reset()
-	h := this.h
-	_, e := h.Write(key)
-	if e != nil {
-		log.Println(e)
+// Fisher-Yates shuffle of a copy of ca using crypto/rand
+func shuffle(ca [][]*big.Int) ([][]*big.Int, error) {
+	out := make([][]*big.Int, len(ca))
+	copy(out, ca)
+	for i := len(out) - 1; i > 0; i-- {
+		j, e := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if e != nil {
+			return nil, e
+		}
+		out[i], out[j.Int64()] = out[j.Int64()], out[i]
 	}
-	s := h.Sum(nil)
-	// Reference: Less Hashing, Same Performance: Building a Better Bloom Filter
-	// URL: http://www.eecs.harvard.edu/~kirsch/pubs/bbbf/rsa.pdf
-	s1 := binary.BigEndian.Uint32(s[0:4])
-	s2 := binary.BigEndian.Uint32(s[4:8])
-
-	for i, _ := range this.bs[:this.k] {
-		this.bs[i] = (uint(s1) + uint(i)*uint(s2)) % this.L
+
+	return out, nil
+}
+
+func (this *EncBloom) setBitset(key []byte) {
+	s := this.Sum(key)
+	if this.layout == bloom.LayoutPartitioned {
+		partitioned.Indices(s, this.L, this.bs[:this.k])
+		return
 	}
+	bloom.Indices(s, this.L, this.bs[:this.k])
 }
@@ -1,9 +1,10 @@
 package encbf
 
 import (
+	"context"
 	"crypto/rand"
+	"github.com/alxdavids/bloom-filter"
 	"github.com/alxdavids/bloom-filter/standard"
-	"github.com/mcornejo/go-go-gadget-paillier"
 	"log"
 	"math/big"
 	"testing"
@@ -18,6 +19,38 @@ var (
 	keySize       = 512
 )
 
+func newEncBloom(sbf bloom.Bloom, mode int) *EncBloom {
+	eblof, e := New(sbf.(*standard.StandardBloom), keySize, mode, maxConc)
+	if e != nil {
+		log.Fatalln(e)
+	}
+
+	return eblof.(*EncBloom)
+}
+
+// Decrypt a single ciphertext with the key held by the filter's owner
+func decrypt(eblof *EncBloom, c *big.Int) ([]byte, error) {
+	m, e := eblof.owner.scheme.Decrypt(c)
+	if e != nil {
+		return nil, e
+	}
+
+	return m.Bytes(), nil
+}
+
+func checkBatch(eblof *EncBloom, keys []*big.Int) *ResultSet {
+	kb := make([][]byte, len(keys))
+	for i, v := range keys {
+		kb[i] = v.Bytes()
+	}
+	rs, e := eblof.CheckBatch(context.Background(), kb, maxConc)
+	if e != nil {
+		log.Fatalln(e)
+	}
+
+	return rs
+}
+
 // Default test to catch stupid errors
 func TestEncBloom(t *testing.T) {
 	sbf := standard.New(n, eps)
@@ -31,10 +64,10 @@ func TestEncBloom(t *testing.T) {
 		sbf = sbf.Add(key)
 	}
 
-	eblof := New(sbf.(*standard.StandardBloom), keySize, 0, maxConc).(*EncBloom)
+	eblof := newEncBloom(sbf, 0)
 	decBf := &bitset.BitSet{}
 	for i, v := range eblof.ebf {
-		m, e := paillier.Decrypt(eblof.priv, v.Bytes())
+		m, e := decrypt(eblof, v)
 		if e != nil {
 			log.Fatalln(e)
 		}
@@ -68,33 +101,30 @@ func TestOps(t *testing.T) {
 		sbf = sbf.Add(key)
 	}
 
-	eblof := New(sbf.(*standard.StandardBloom), keySize, 0, maxConc).(*EncBloom)
+	eblof := newEncBloom(sbf, 0)
 	unionTest(keys, eblof)
 
 	//reset array and do intersection
-	eblof = New(sbf.(*standard.StandardBloom), keySize, 1, maxConc).(*EncBloom)
+	eblof = newEncBloom(sbf, 1)
 	interTest(keys, eblof)
 
 	//reset array and do intersection
-	eblof = New(sbf.(*standard.StandardBloom), keySize, 2, maxConc).(*EncBloom)
+	eblof = newEncBloom(sbf, 2)
 	caTest(keys, eblof)
 }
 
 func unionTest(keys []*big.Int, eblof *EncBloom) {
 	// Check elements that already exist
-	for _, v := range keys {
-		eblof.Check(v.Bytes())
-	}
-	eblof.HomCombine()
+	rs := checkBatch(eblof, keys)
 
-	for i := range eblof.ca {
-		pair := eblof.ca[i]
+	for i := range rs.Results {
+		pair := rs.Results[i].Ciphertexts
 
-		m0, e := paillier.Decrypt(eblof.priv, pair[0].Bytes())
+		m0, e := decrypt(eblof, pair[0])
 		if e != nil {
 			log.Fatalln(e)
 		}
-		m1, e := paillier.Decrypt(eblof.priv, pair[1].Bytes())
+		m1, e := decrypt(eblof, pair[1])
 		if e != nil {
 			log.Fatalln(e)
 		}
@@ -112,43 +142,34 @@ func unionTest(keys []*big.Int, eblof *EncBloom) {
 	if e != nil {
 		log.Fatalln(e)
 	}
-	key := r.Bytes()
-
-	eblof.ca = [][]*big.Int{}
-	eblof.tmpCa = map[string][]*big.Int{}
-	eblof.Check(key)
-	eblof.HomCombine()
-	pair := eblof.ca[0]
+	pair := checkBatch(eblof, []*big.Int{r}).Results[0].Ciphertexts
 
-	m0, e := paillier.Decrypt(eblof.priv, pair[0].Bytes())
+	m0, e := decrypt(eblof, pair[0])
 	if e != nil {
 		log.Fatalln(e)
 	}
-	m1, e := paillier.Decrypt(eblof.priv, pair[1].Bytes())
+	m1, e := decrypt(eblof, pair[1])
 	if e != nil {
 		log.Fatalln(e)
 	}
 
-	cinv := new(big.Int).ModInverse(new(big.Int).SetBytes(m1), eblof.pub.N)
-	if new(big.Int).Mod(new(big.Int).Mul(new(big.Int).SetBytes(m0), cinv), eblof.pub.N).Cmp(r) != 0 {
+	cinv := new(big.Int).ModInverse(new(big.Int).SetBytes(m1), eblof.scheme.PlaintextModulus())
+	if new(big.Int).Mod(new(big.Int).Mul(new(big.Int).SetBytes(m0), cinv), eblof.scheme.PlaintextModulus()).Cmp(r) != 0 {
 		log.Fatalln("Failed to recover union element")
 	}
 }
 
 func interTest(keys []*big.Int, eblof *EncBloom) {
-	for _, v := range keys {
-		eblof.Check(v.Bytes())
-	}
-	eblof.HomCombine()
+	rs := checkBatch(eblof, keys)
 
-	for i := range eblof.ca {
-		pair := eblof.ca[i]
+	for i := range rs.Results {
+		pair := rs.Results[i].Ciphertexts
 
-		m0, e := paillier.Decrypt(eblof.priv, pair[0].Bytes())
+		m0, e := decrypt(eblof, pair[0])
 		if e != nil {
 			log.Fatalln(e)
 		}
-		m1, e := paillier.Decrypt(eblof.priv, pair[1].Bytes())
+		m1, e := decrypt(eblof, pair[1])
 		if e != nil {
 			log.Fatalln(e)
 		}
@@ -174,15 +195,9 @@ func interTest(keys []*big.Int, eblof *EncBloom) {
 	if e != nil {
 		log.Fatalln(e)
 	}
-	key := r.Bytes()
+	pair := checkBatch(eblof, []*big.Int{r}).Results[0].Ciphertexts
 
-	eblof.ca = [][]*big.Int{}
-	eblof.tmpCa = map[string][]*big.Int{}
-	eblof.Check(key)
-	eblof.HomCombine()
-	pair := eblof.ca[0]
-
-	m1, e := paillier.Decrypt(eblof.priv, pair[1].Bytes())
+	m1, e := decrypt(eblof, pair[1])
 	if e != nil {
 		log.Fatalln(e)
 	}
@@ -193,15 +208,12 @@ func interTest(keys []*big.Int, eblof *EncBloom) {
 }
 
 func caTest(keys []*big.Int, eblof *EncBloom) {
-	for _, v := range keys {
-		eblof.Check(v.Bytes())
-	}
-	eblof.HomCombine()
+	rs := checkBatch(eblof, keys)
 
-	for i := range eblof.ca {
-		out := eblof.ca[i]
+	for i := range rs.Results {
+		out := rs.Results[i].Ciphertexts
 
-		m, e := paillier.Decrypt(eblof.priv, out[0].Bytes())
+		m, e := decrypt(eblof, out[0])
 		if e != nil {
 			log.Fatalln(e)
 		}
@@ -215,15 +227,9 @@ func caTest(keys []*big.Int, eblof *EncBloom) {
 	if e != nil {
 		log.Fatalln(e)
 	}
-	key := r.Bytes()
+	out := checkBatch(eblof, []*big.Int{r}).Results[0].Ciphertexts
 
-	eblof.ca = [][]*big.Int{}
-	eblof.tmpCa = map[string][]*big.Int{}
-	eblof.Check(key)
-	eblof.HomCombine()
-	out := eblof.ca[0]
-
-	m, e := paillier.Decrypt(eblof.priv, out[0].Bytes())
+	m, e := decrypt(eblof, out[0])
 	if e != nil {
 		log.Fatalln(e)
 	}
@@ -232,3 +238,87 @@ func caTest(keys []*big.Int, eblof *EncBloom) {
 		log.Fatalln("Shouldn't be encryption of zero [car]")
 	}
 }
+
+// The same query must give unrelated ciphertexts in ModeCA, or the shuffle
+// would not hide which queries matched
+func TestCaRerandomized(t *testing.T) {
+	sbf := standard.New(n, eps)
+	sbf.Add([]byte{1})
+	eblof := newEncBloom(sbf, ModeCA)
+
+	keys := []*big.Int{big.NewInt(1), big.NewInt(2)}
+	a, b := checkBatch(eblof, keys), checkBatch(eblof, keys)
+	for i := range keys {
+		if a.Results[i].Ciphertexts[0].Cmp(b.Results[i].Ciphertexts[0]) == 0 {
+			log.Fatalln("Combined ciphertexts should be rerandomized")
+		}
+	}
+
+	// The Owner cannot recover the blinding factor of a miss and recompute
+	// the result from its own ciphertexts
+	s := eblof.owner.scheme
+	eblof.setBitset(keys[1].Bytes())
+	c := eblof.ebf[eblof.bs[0]]
+	for _, v := range eblof.bs[1:eblof.k] {
+		c = s.AddCipher(c, eblof.ebf[v])
+	}
+	y := a.Results[1].Ciphertexts[0]
+	my, e := s.Decrypt(y)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	mc, e := s.Decrypt(c)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	N := s.PlaintextModulus()
+	r := new(big.Int).ModInverse(mc, N)
+	r.Mul(r, my).Mod(r, N)
+	if s.ScalarMul(c, r).Cmp(y) == 0 {
+		log.Fatalln("Cardinality result is linkable to the queried element")
+	}
+}
+
+func TestCheckBatch(t *testing.T) {
+	sbf := standard.New(n, eps)
+	eblof := newEncBloom(sbf, ModePSI)
+
+	// Duplicates are kept and every result matches its input
+	keys := make([]*big.Int, 3*int(n))
+	for i := range keys {
+		keys[i] = big.NewInt(int64(i%int(2*n)) + 1)
+	}
+	rs := checkBatch(eblof, keys)
+	if len(rs.Results) != len(keys) {
+		log.Fatalln("Expected one result per key, got", len(rs.Results))
+	}
+	for i, v := range rs.Results {
+		if new(big.Int).SetBytes(v.Key).Cmp(keys[i]) != 0 {
+			log.Fatalln("Results are not in input order")
+		}
+		// Nothing is in the filter, so the element is blinded
+		m, e := decrypt(eblof, v.Ciphertexts[0])
+		if e != nil {
+			log.Fatalln(e)
+		}
+		if new(big.Int).SetBytes(m).Cmp(keys[i]) == 0 {
+			log.Fatalln("Element outside the filter should be blinded")
+		}
+		// Repeated queries must not be linkable to the filter's ciphertexts
+		if i >= int(2*n) && v.Ciphertexts[1].Cmp(rs.Results[i-int(2*n)].Ciphertexts[1]) == 0 {
+			log.Fatalln("Combined ciphertexts should be rerandomized")
+		}
+	}
+
+	for _, workers := range []int{0, -1} {
+		if _, e := eblof.CheckBatch(context.Background(), [][]byte{{1}}, workers); e != ErrConcurrency {
+			log.Fatalln("Expected concurrency error, got", e)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, e := eblof.CheckBatch(ctx, [][]byte{{1}}, maxConc); e != context.Canceled {
+		log.Fatalln("Expected cancellation, got", e)
+	}
+}
@@ -0,0 +1,46 @@
+package encbf
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mcornejo/go-go-gadget-paillier"
+)
+
+var (
+	// ErrMessageTooLong is returned when a queried element or plaintext does
+	// not fit below the Paillier modulus
+	ErrMessageTooLong = paillier.ErrMessageTooLong
+	ErrNoPrivateKey   = errors.New("encbf: no private key is held by this encrypted Bloom filter")
+	ErrMode           = errors.New("encbf: unknown PSO mode")
+	ErrConcurrency    = errors.New("encbf: maxConcurrentGoroutines must be at least 1")
+)
+
+// QueryError reports a failure to combine the ciphertexts of one queried
+// element, so that callers can reject that element and carry on.
+type QueryError struct {
+	Key []byte
+	Err error
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("encbf: query %x: %v", e.Key, e.Err)
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// ResultError reports a failure to decrypt the combined ciphertexts at
+// position Index of a result set.
+type ResultError struct {
+	Index int
+	Err   error
+}
+
+func (e *ResultError) Error() string {
+	return fmt.Sprintf("encbf: result %d: %v", e.Index, e.Err)
+}
+
+func (e *ResultError) Unwrap() error {
+	return e.Err
+}
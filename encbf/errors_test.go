@@ -0,0 +1,50 @@
+package encbf
+
+import (
+	"context"
+	"errors"
+	"github.com/alxdavids/bloom-filter/standard"
+	"log"
+	"math/big"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	sbf := standard.New(n, eps)
+	owner, e := NewOwner(keySize)
+	if e != nil {
+		log.Fatalln(e)
+	}
+
+	if _, e := owner.Encrypt(sbf.(*standard.StandardBloom), ModeCA+1, maxConc); e != ErrMode {
+		log.Fatalln("Expected mode error, got", e)
+	}
+
+	for _, workers := range []int{0, -1} {
+		if _, e := owner.EncryptFast(sbf.(*standard.StandardBloom), ModePSI, workers); e != ErrConcurrency {
+			log.Fatalln("Expected concurrency error, got", e)
+		}
+	}
+
+	// Elements must be smaller than the modulus in PSU and PSI
+	for _, mode := range []int{ModePSU, ModePSI} {
+		eb, e := owner.Encrypt(sbf.(*standard.StandardBloom), mode, maxConc)
+		if e != nil {
+			log.Fatalln(e)
+		}
+		key := new(big.Int).Add(owner.Public().PlaintextModulus(), big.NewInt(1)).Bytes()
+		_, e = eb.CheckBatch(context.Background(), [][]byte{{1}, key}, maxConc)
+		var qe *QueryError
+		if !errors.As(e, &qe) || !errors.Is(e, ErrMessageTooLong) || string(qe.Key) != string(key) {
+			log.Fatalln("Expected query error for oversized element, got", e)
+		}
+	}
+
+	// Ciphertexts outside Z_{N^2} are rejected
+	bad := [][]*big.Int{{big.NewInt(1)}, {new(big.Int).Mul(owner.Public().PlaintextModulus(), owner.Public().PlaintextModulus())}}
+	_, e = owner.Decrypt(bad, maxConc)
+	var re *ResultError
+	if !errors.As(e, &re) || re.Index != 1 || !errors.Is(e, ErrMessageTooLong) {
+		log.Fatalln("Expected result error for invalid ciphertext, got", e)
+	}
+}
@@ -0,0 +1,291 @@
+package encbf
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"github.com/alxdavids/bloom-filter"
+	"io"
+	"math"
+	"math/big"
+)
+
+// Serialized layout (big-endian). Only public material is written, the
+// receiver can run CheckBatch but not Decrypt.
+//
+//	magic   [4]byte "YBEF"
+//	version uint8
+//	hasher  uint8   bloom.HasherID
+//	mode    uint8
+//	scheme  uint8   SchemeID
+//	layout  uint8   bloom.Layout
+//	keychk  [8]byte bloom.KeyCheck of the hasher key
+//	L, k, n uint64
+//	eps     uint64  IEEE 754 bits
+//	pubkey  uint32 length || Scheme.MarshalPublicKey
+//	ebf     L * (uint32 length || bytes)
+const marshalVersion = 5
+
+var marshalMagic = [4]byte{'Y', 'B', 'E', 'F'}
+
+var (
+	ErrInvalidEncoding = errors.New("encbf: invalid serialized encrypted Bloom filter")
+	ErrVersion         = errors.New("encbf: unsupported serialization version")
+	ErrCustomHasher    = errors.New("encbf: cannot serialize a filter with a custom hasher")
+)
+
+var _ encoding.BinaryMarshaler = (*EncBloom)(nil)
+
+func (this *EncBloom) MarshalBinary() ([]byte, error) {
+	if this.HasherID() == bloom.HasherCustom {
+		return nil, ErrCustomHasher
+	}
+
+	var buf bytes.Buffer
+	buf.Write(marshalMagic[:])
+	buf.WriteByte(marshalVersion)
+	buf.WriteByte(byte(this.HasherID()))
+	buf.WriteByte(byte(this.mode))
+	buf.WriteByte(byte(this.scheme.ID()))
+	buf.WriteByte(byte(this.layout))
+	kc := this.HasherKeyCheck()
+	buf.Write(kc[:])
+	for _, v := range []uint64{uint64(this.L), uint64(this.k), uint64(this.n), math.Float64bits(this.eps)} {
+		binary.Write(&buf, binary.BigEndian, v)
+	}
+
+	writeBytes(&buf, this.scheme.MarshalPublicKey())
+	for _, c := range this.ebf[:this.L] {
+		writeInt(&buf, c)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// NewPublic rebuilds an encrypted Bloom filter written by MarshalBinary. The
+// result holds only the public key, so it can query and combine ciphertexts
+// but cannot decrypt them.
+func NewPublic(data []byte) (*EncBloom, error) {
+	return NewPublicKeyed(data, nil)
+}
+
+// NewPublicKeyed is NewPublic for filters built with a keyed hasher, which
+// the evaluator must share with the Owner. It returns
+// bloom.ErrHasherMismatch if hkey is not the key the filter was built with.
+func NewPublicKeyed(data, hkey []byte) (*EncBloom, error) {
+	r := bytes.NewReader(data)
+
+	var hdr [17]byte
+	if _, e := io.ReadFull(r, hdr[:]); e != nil || !bytes.Equal(hdr[0:4], marshalMagic[:]) {
+		return nil, ErrInvalidEncoding
+	}
+	if hdr[4] != marshalVersion {
+		return nil, ErrVersion
+	}
+	hid := bloom.HasherID(hdr[5])
+	mode := int(hdr[6])
+	s, ok := schemeByID(SchemeID(hdr[7]))
+	layout := bloom.Layout(hdr[8])
+	if mode > ModeCA || !ok || layout > bloom.LayoutPartitioned {
+		return nil, ErrInvalidEncoding
+	}
+	if _, ok := s.(*ElGamal); ok && mode != ModeCA {
+		return nil, ErrMode
+	}
+	var kc [8]byte
+	copy(kc[:], hdr[9:17])
+	if e := bloom.CheckHasher(hid, kc, hkey); e != nil {
+		return nil, e
+	}
+	var hs bloom.Hasher
+	hs.UseHasher(hid, hkey)
+
+	var params [4]uint64
+	if e := binary.Read(r, binary.BigEndian, &params); e != nil {
+		return nil, ErrInvalidEncoding
+	}
+	L, k, n, eps := params[0], params[1], params[2], math.Float64frombits(params[3])
+	if L == 0 || k == 0 || k > L || k > bloom.MaxK || !(eps > 0 && eps < 1) || L > uint64(len(data)) ||
+		(layout == bloom.LayoutPartitioned && L%k != 0) {
+		return nil, ErrInvalidEncoding
+	}
+
+	pk, e := readBytes(r)
+	if e != nil {
+		return nil, ErrInvalidEncoding
+	}
+	pub, e := s.UnmarshalPublicKey(pk)
+	if e != nil {
+		return nil, e
+	}
+
+	ebf := make([]*big.Int, L)
+	for i := range ebf {
+		ebf[i], e = readInt(r)
+		if e != nil || pub.CheckCiphertext(ebf[i]) != nil {
+			return nil, ErrInvalidEncoding
+		}
+	}
+	if r.Len() != 0 {
+		return nil, ErrInvalidEncoding
+	}
+
+	return &EncBloom{
+		Hasher: hs,
+		k:      uint(k),
+		L:      uint(L),
+		eps:    eps,
+		n:      uint(n),
+		ebf:    ebf,
+		bs:     make([]uint, uint(k)),
+		m:      uint(n),
+		scheme: pub,
+		mode:   mode,
+		layout: layout,
+	}, nil
+}
+
+// MarshalResults encodes the combined ciphertexts returned by
+// ResultSet.Ciphertexts as the uint8 ResultSet.Mode and a uint32 count
+// followed by, for each result, a uint8 count of ciphertexts and the
+// length-prefixed ciphertexts. Results hold two ciphertexts in ModePSU and
+// ModePSI and one in ModeCA.
+func MarshalResults(mode int, ca [][]*big.Int) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(byte(mode))
+	binary.Write(&buf, binary.BigEndian, uint32(len(ca)))
+	for _, v := range ca {
+		buf.WriteByte(byte(len(v)))
+		for _, c := range v {
+			writeInt(&buf, c)
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// UnmarshalResults returns the mode and the combined ciphertexts written by
+// MarshalResults. Every ciphertext is checked against the scheme s.
+func UnmarshalResults(s Scheme, data []byte) (int, [][]*big.Int, error) {
+	r := bytes.NewReader(data)
+
+	mode, e := r.ReadByte()
+	if e != nil || mode > ModeCA {
+		return 0, nil, ErrInvalidEncoding
+	}
+	var l uint32
+	if e := binary.Read(r, binary.BigEndian, &l); e != nil || int64(l) > int64(r.Len()) {
+		return 0, nil, ErrInvalidEncoding
+	}
+	want := byte(2)
+	if mode == ModeCA {
+		want = 1
+	}
+	ca := make([][]*big.Int, l)
+	for i := range ca {
+		m, e := r.ReadByte()
+		if e != nil || m != want {
+			return 0, nil, ErrInvalidEncoding
+		}
+		ca[i] = make([]*big.Int, m)
+		for j := range ca[i] {
+			if ca[i][j], e = readInt(r); e != nil || s.CheckCiphertext(ca[i][j]) != nil {
+				return 0, nil, ErrInvalidEncoding
+			}
+		}
+	}
+	if r.Len() != 0 {
+		return 0, nil, ErrInvalidEncoding
+	}
+
+	return int(mode), ca, nil
+}
+
+func writeInt(w io.Writer, x *big.Int) {
+	writeBytes(w, x.Bytes())
+}
+
+func readInt(r *bytes.Reader) (*big.Int, error) {
+	b, e := readBytes(r)
+	if e != nil {
+		return nil, e
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
+
+func writeBytes(w io.Writer, b []byte) {
+	binary.Write(w, binary.BigEndian, uint32(len(b)))
+	w.Write(b)
+}
+
+func readBytes(r *bytes.Reader) ([]byte, error) {
+	var l uint32
+	if e := binary.Read(r, binary.BigEndian, &l); e != nil {
+		return nil, e
+	}
+	if int64(l) > int64(r.Len()) {
+		return nil, ErrInvalidEncoding
+	}
+	b := make([]byte, l)
+	if _, e := io.ReadFull(r, b); e != nil {
+		return nil, e
+	}
+
+	return b, nil
+}
+
+// Serialized Owner key (big-endian). This holds the private key and must be
+// kept secret.
+//
+//	magic   [4]byte "YBEK"
+//	version uint8
+//	scheme  uint8   SchemeID
+//	key     PrivateKeyMarshaler.MarshalPrivateKey
+const ownerVersion = 1
+
+var ownerMagic = [4]byte{'Y', 'B', 'E', 'K'}
+
+var _ encoding.BinaryMarshaler = (*Owner)(nil)
+
+// MarshalBinary encodes the Owner's private key for NewOwnerFromKey
+func (this *Owner) MarshalBinary() ([]byte, error) {
+	pm, ok := this.scheme.(PrivateKeyMarshaler)
+	if !ok {
+		return nil, ErrNoPrivateKey
+	}
+	key, e := pm.MarshalPrivateKey()
+	if e != nil {
+		return nil, e
+	}
+
+	var buf bytes.Buffer
+	buf.Write(ownerMagic[:])
+	buf.WriteByte(ownerVersion)
+	buf.WriteByte(byte(this.scheme.ID()))
+	buf.Write(key)
+
+	return buf.Bytes(), nil
+}
+
+// NewOwnerFromKey restores an Owner written by Owner.MarshalBinary
+func NewOwnerFromKey(data []byte) (*Owner, error) {
+	if len(data) < 6 || !bytes.Equal(data[0:4], ownerMagic[:]) {
+		return nil, ErrInvalidEncoding
+	}
+	if data[4] != ownerVersion {
+		return nil, ErrVersion
+	}
+	s, _ := schemeByID(SchemeID(data[5]))
+	pm, ok := s.(PrivateKeyMarshaler)
+	if !ok {
+		return nil, ErrInvalidEncoding
+	}
+	s, e := pm.UnmarshalPrivateKey(data[6:])
+	if e != nil {
+		return nil, e
+	}
+
+	return &Owner{scheme: s}, nil
+}
@@ -0,0 +1,190 @@
+package encbf
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"github.com/alxdavids/bloom-filter"
+	"github.com/alxdavids/bloom-filter/standard"
+	"log"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestMarshal(t *testing.T) {
+	sbf := standard.New(n, eps)
+
+	keys := make([]*big.Int, int(n))
+	for i := 0; i < int(n); i++ {
+		r, e := rand.Int(rand.Reader, big.NewInt(max))
+		if e != nil {
+			log.Fatalln(e)
+		}
+		keys[i] = r
+		sbf = sbf.Add(r.Bytes())
+	}
+
+	eblof := newEncBloom(sbf, 0)
+	data, e := eblof.MarshalBinary()
+	if e != nil {
+		log.Fatalln(e)
+	}
+
+	remote, e := NewPublic(data)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	if remote.owner != nil {
+		log.Fatalln("Private key should not be transferred")
+	}
+	if remote.L != eblof.L || remote.k != eblof.k || remote.mode != eblof.mode || remote.scheme.PlaintextModulus().Cmp(eblof.scheme.PlaintextModulus()) != 0 {
+		log.Fatalln("Parameters differ after unmarshalling")
+	}
+	for i := range eblof.ebf {
+		if remote.ebf[i].Cmp(eblof.ebf[i]) != 0 {
+			log.Fatalln("Ciphertexts differ after unmarshalling")
+		}
+	}
+
+	// Results combined remotely must decrypt under the original key
+	remote.owner = eblof.owner
+	unionTest(keys, remote)
+
+	data[4] = marshalVersion + 1
+	if _, e := NewPublic(data); e != ErrVersion {
+		log.Fatalln("Expected version error, got", e)
+	}
+	data[4] = marshalVersion
+	if _, e := NewPublic(data[:len(data)-1]); e != ErrInvalidEncoding {
+		log.Fatalln("Expected encoding error, got", e)
+	}
+
+	// Tampered headers: k out of range and eps outside (0, 1). The
+	// parameters follow the 17-byte header as L, k, n, eps.
+	for _, tamper := range []struct {
+		off int
+		v   uint64
+	}{{25, 1 << 62}, {25, uint64(eblof.L) + 1}, {41, math.Float64bits(0)}, {41, math.Float64bits(1)}, {41, math.Float64bits(math.NaN())}} {
+		bad := append([]byte(nil), data...)
+		binary.BigEndian.PutUint64(bad[tamper.off:tamper.off+8], tamper.v)
+		if _, e := NewPublic(bad); e != ErrInvalidEncoding {
+			log.Fatalln("Expected encoding error for tampered header, got", e)
+		}
+	}
+}
+
+func TestMarshalResults(t *testing.T) {
+	owner, e := NewOwner(keySize)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	pub := owner.Public()
+	ca := [][]*big.Int{{big.NewInt(1), big.NewInt(2)}, {big.NewInt(3), big.NewInt(4)}}
+	data := MarshalResults(ModePSI, ca)
+	mode, loaded, e := UnmarshalResults(pub, data)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	if mode != ModePSI || len(loaded) != 2 || len(loaded[1]) != 2 || loaded[1][1].Int64() != 4 {
+		log.Fatalln("Results differ after unmarshalling")
+	}
+
+	// Rows must hold a pair, or a single ciphertext in ModeCA
+	if _, _, e := UnmarshalResults(pub, MarshalResults(ModePSI, [][]*big.Int{{big.NewInt(1)}})); e != ErrInvalidEncoding {
+		log.Fatalln("Expected encoding error for a short row, got", e)
+	}
+	if _, _, e := UnmarshalResults(pub, MarshalResults(ModeCA, ca)); e != ErrInvalidEncoding {
+		log.Fatalln("Expected encoding error for a long row, got", e)
+	}
+
+	// Ciphertexts outside the scheme are rejected
+	if _, _, e := UnmarshalResults(pub, MarshalResults(ModePSI, [][]*big.Int{{big.NewInt(1), big.NewInt(0)}})); e != ErrInvalidEncoding {
+		log.Fatalln("Expected encoding error for an invalid ciphertext, got", e)
+	}
+
+	data[0] = ModeCA + 1
+	if _, _, e := UnmarshalResults(pub, data); e != ErrInvalidEncoding {
+		log.Fatalln("Expected encoding error for an unknown mode, got", e)
+	}
+	data[0] = ModePSI
+	if _, _, e := UnmarshalResults(pub, data[:len(data)-1]); e != ErrInvalidEncoding {
+		log.Fatalln("Expected encoding error, got", e)
+	}
+}
+
+func TestMarshalKeyed(t *testing.T) {
+	key := []byte("shared secret")
+	sbf := standard.New(n, eps).(*standard.StandardBloom)
+	if e := sbf.UseHasher(bloom.HasherHMACSHA256, key); e != nil {
+		log.Fatalln(e)
+	}
+	sbf.Add([]byte{7})
+
+	eblof := newEncBloom(sbf, ModeCA)
+	data, e := eblof.MarshalBinary()
+	if e != nil {
+		log.Fatalln(e)
+	}
+	if _, e := NewPublic(data); e != bloom.ErrHasherKey {
+		log.Fatalln("Expected key error, got", e)
+	}
+	if _, e := NewPublicKeyed(data, []byte("wrong")); e != bloom.ErrHasherMismatch {
+		log.Fatalln("Expected mismatch error, got", e)
+	}
+	remote, e := NewPublicKeyed(data, key)
+	if e != nil {
+		log.Fatalln(e)
+	}
+
+	rs, e := remote.CheckBatch(context.Background(), [][]byte{{7}}, maxConc)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	m, e := decrypt(eblof, rs.Results[0].Ciphertexts[0])
+	if e != nil {
+		log.Fatalln(e)
+	}
+	if new(big.Int).SetBytes(m).Sign() != 0 {
+		log.Fatalln("Element should be found with the shared key")
+	}
+}
+
+func TestMarshalOwner(t *testing.T) {
+	for _, s := range []Scheme{&Paillier{}, &ElGamal{}} {
+		owner, e := NewOwnerWithScheme(s, keySize)
+		if e != nil {
+			log.Fatalln(e)
+		}
+		data, e := owner.MarshalBinary()
+		if e != nil {
+			log.Fatalln(e)
+		}
+		loaded, e := NewOwnerFromKey(data)
+		if e != nil {
+			log.Fatalln(e)
+		}
+
+		// A ciphertext from the original decrypts with the restored key
+		c, e := owner.Public().Encrypt(big.NewInt(42))
+		if e != nil {
+			log.Fatalln(e)
+		}
+		m, e := loaded.Scheme().Decrypt(c)
+		if e != nil || m.Int64() != 42 {
+			log.Fatalln("Restored key does not decrypt", e)
+		}
+		if string(loaded.Public().MarshalPublicKey()) != string(owner.Public().MarshalPublicKey()) {
+			log.Fatalln("Public key differs after restoring the private key")
+		}
+
+		data[4] = ownerVersion + 1
+		if _, e := NewOwnerFromKey(data); e != ErrVersion {
+			log.Fatalln("Expected version error, got", e)
+		}
+	}
+
+	if _, e := (&Paillier{}).UnmarshalPrivateKey([]byte{0, 0, 0, 1, 4, 0, 0, 0, 1, 6}); e != ErrInvalidEncoding {
+		log.Fatalln("Expected encoding error for composite factors, got", e)
+	}
+}
@@ -0,0 +1,266 @@
+package encbf
+
+import (
+	"crypto/rand"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+	"xojoc.pw/bitset"
+)
+
+// Owner is the key-holding side of the protocol. It encrypts its own
+// StandardBloom for the evaluator and decrypts the combined ciphertexts the
+// evaluator sends back. The private key never leaves the Owner.
+type Owner struct {
+	scheme Scheme // encryption scheme holding the private key
+}
+
+// NewOwner generates a Paillier keypair of keySize bits
+func NewOwner(keySize int) (*Owner, error) {
+	return NewOwnerWithScheme(&Paillier{}, keySize)
+}
+
+// NewOwnerWithScheme generates a keypair of keySize bits for s
+func NewOwnerWithScheme(s Scheme, keySize int) (*Owner, error) {
+	keyTime := time.Now()
+	scheme, e := s.KeyGen(rand.Reader, keySize)
+	if e != nil {
+		return nil, e
+	}
+	log.Printf("Key time: %v", time.Since(keyTime).Seconds())
+
+	return &Owner{scheme: scheme}, nil
+}
+
+// Encrypt the bits of sbf under the public key. The returned EncBloom holds
+// no private material and can be handed to the evaluator (see MarshalBinary).
+func (this *Owner) Encrypt(sbf Filter, mode, maxConcurrentGoroutines int) (*EncBloom, error) {
+	pub := this.scheme.Public()
+
+	return this.encrypt(sbf, mode, maxConcurrentGoroutines, func(m *big.Int) (*big.Int, error) {
+		return pub.Encrypt(m)
+	})
+}
+
+// EncryptFast produces the same filter as Encrypt, but every bit is a
+// rerandomization of one of two ciphertexts, Enc(0) or Enc(1), computed once.
+// Rerandomizing with the Owner's scheme lets Paillier use the factors of N.
+func (this *Owner) EncryptFast(sbf Filter, mode, maxConcurrentGoroutines int) (*EncBloom, error) {
+	c0, e := this.scheme.Encrypt(big.NewInt(0))
+	if e != nil {
+		return nil, e
+	}
+	c1, e := this.scheme.Encrypt(big.NewInt(1))
+	if e != nil {
+		return nil, e
+	}
+
+	return this.encrypt(sbf, mode, maxConcurrentGoroutines, func(m *big.Int) (*big.Int, error) {
+		if m.Sign() == 0 {
+			return this.scheme.Rerandomize(c0)
+		}
+		return this.scheme.Rerandomize(c1)
+	})
+}
+
+// encrypt runs enc over the plaintext bits of sbf with a bounded number of
+// goroutines
+func (this *Owner) encrypt(sbf Filter, mode, maxConcurrentGoroutines int, enc func(*big.Int) (*big.Int, error)) (*EncBloom, error) {
+	if mode < ModePSU || mode > ModeCA {
+		return nil, ErrMode
+	}
+	if maxConcurrentGoroutines < 1 {
+		return nil, ErrConcurrency
+	}
+	if _, ok := this.scheme.(*ElGamal); ok && mode != ModeCA {
+		return nil, ErrMode
+	}
+	_, L, k, n, eps, sbfa := sbf.GetParams()
+
+	// construct ciphertexts for bloom filter
+	ebf := make([]*big.Int, uint(L))
+
+	// Use this channel for limiting goroutines
+	concurrentGoroutines := make(chan struct{}, maxConcurrentGoroutines)
+	for i := 0; i < maxConcurrentGoroutines; i++ {
+		concurrentGoroutines <- struct{}{}
+	}
+	done := make(chan error)
+	waitForAllJobs := make(chan error)
+
+	// Collect all the jobs, and since the job is finished, we can
+	// release another spot for a goroutine.
+	go func() {
+		var err error
+		for i := uint(0); i < L; i++ {
+			if e := <-done; e != nil && err == nil {
+				err = e
+			}
+			// Say that another goroutine can now start.
+			concurrentGoroutines <- struct{}{}
+		}
+		// We have collected all the jobs, the program
+		// can now terminate
+		waitForAllJobs <- err
+	}()
+
+	// Start the encryption process with limited goroutines
+	encTime := time.Now()
+	for i := uint(0); i < L; i++ {
+		// Wait till we're allowed to go
+		<-concurrentGoroutines
+
+		go func(i uint, ebf []*big.Int, sbfa *bitset.BitSet) {
+			var m *big.Int
+			// Remember that we operate over an encrypted Bloom filter
+			if sbfa.Get(int(i)) {
+				m = big.NewInt(0)
+			} else {
+				m = big.NewInt(1)
+			}
+			c, e := enc(m)
+			if e != nil {
+				done <- e
+				return
+			}
+			ebf[i] = c
+
+			done <- nil
+		}(i, ebf, sbfa)
+	}
+	if e := <-waitForAllJobs; e != nil {
+		return nil, e
+	}
+	log.Printf("Enc time: %v", time.Since(encTime).Seconds())
+
+	// A copy of the hasher keeps the filters usable from different goroutines
+	return &EncBloom{
+		Hasher: sbf.CloneHasher(),
+		k:      k,
+		L:      L,
+		eps:    eps,
+		n:      n,
+		ebf:    ebf,
+		bs:     make([]uint, uint(k)),
+		m:      n,
+		scheme: this.scheme.Public(),
+		mode:   mode,
+		layout: sbf.Layout(),
+	}, nil
+}
+
+// Plaintext is the decryption of the combined ciphertexts for one element.
+// M1 is nil in ModeCA, where a single ciphertext is returned.
+type Plaintext struct {
+	M0, M1 *big.Int
+}
+
+// Decrypt the combined ciphertexts returned by the evaluator, using at most
+// maxConcurrentGoroutines workers. Results are in the order of ca, and on
+// failure the *ResultError with the lowest index is returned.
+func (this *Owner) Decrypt(ca [][]*big.Int, maxConcurrentGoroutines int) ([]Plaintext, error) {
+	if maxConcurrentGoroutines < 1 {
+		return nil, ErrConcurrency
+	}
+	ptxts := make([]Plaintext, len(ca))
+	errs := make([]error, len(ca))
+
+	// Use this channel for limiting goroutines
+	concurrentGoroutines := make(chan struct{}, maxConcurrentGoroutines)
+	for i := 0; i < maxConcurrentGoroutines; i++ {
+		concurrentGoroutines <- struct{}{}
+	}
+
+	var wg sync.WaitGroup
+	for i, v := range ca {
+		// Wait till we're allowed to go
+		<-concurrentGoroutines
+
+		wg.Add(1)
+		go func(i int, v []*big.Int) {
+			defer func() {
+				concurrentGoroutines <- struct{}{}
+				wg.Done()
+			}()
+			ptxts[i], errs[i] = this.decrypt(v)
+		}(i, v)
+	}
+	wg.Wait()
+
+	for i, e := range errs {
+		if e != nil {
+			return nil, &ResultError{Index: i, Err: e}
+		}
+	}
+
+	return ptxts, nil
+}
+
+func (this *Owner) decrypt(v []*big.Int) (Plaintext, error) {
+	var p Plaintext
+	if len(v) == 0 {
+		return p, ErrInvalidEncoding
+	}
+	m, e := this.scheme.Decrypt(v[0])
+	if e != nil {
+		return p, e
+	}
+	p.M0 = m
+	if len(v) > 1 {
+		if p.M1, e = this.scheme.Decrypt(v[1]); e != nil {
+			return p, e
+		}
+	}
+
+	return p, nil
+}
+
+// Cardinality decrypts the results of a ModeCA evaluation and returns the
+// sizes of the intersection and union. setSize is the number of elements the
+// Owner inserted into its filter, the size of the evaluator's set is the
+// number of results. Decryption uses at most maxConcurrentGoroutines workers.
+func (this *Owner) Cardinality(ca [][]*big.Int, setSize, maxConcurrentGoroutines int) (int, int, error) {
+	if maxConcurrentGoroutines < 1 {
+		return 0, 0, ErrConcurrency
+	}
+	inter := 0
+	if zt, ok := this.scheme.(ZeroTester); ok {
+		for i, v := range ca {
+			if len(v) == 0 {
+				return 0, 0, &ResultError{Index: i, Err: ErrInvalidEncoding}
+			}
+			z, e := zt.IsZero(v[0])
+			if e != nil {
+				return 0, 0, &ResultError{Index: i, Err: e}
+			}
+			if z {
+				inter++
+			}
+		}
+
+		return inter, setSize + len(ca) - inter, nil
+	}
+
+	ptxts, e := this.Decrypt(ca, maxConcurrentGoroutines)
+	if e != nil {
+		return 0, 0, e
+	}
+	for _, v := range ptxts {
+		if v.M0.Sign() == 0 {
+			inter++
+		}
+	}
+
+	return inter, setSize + len(ca) - inter, nil
+}
+
+// Scheme returns the Owner's scheme, including the private key
+func (this *Owner) Scheme() Scheme {
+	return this.scheme
+}
+
+// Public returns the Owner's scheme without the private key
+func (this *Owner) Public() Scheme {
+	return this.scheme.Public()
+}
@@ -0,0 +1,237 @@
+package encbf
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"github.com/alxdavids/bloom-filter"
+	"github.com/alxdavids/bloom-filter/partitioned"
+	"github.com/alxdavids/bloom-filter/standard"
+	"log"
+	"math/big"
+	"runtime"
+	"testing"
+)
+
+// Run the CA mode with the roles split over the wire format
+func TestOwnerEvaluator(t *testing.T) {
+	sbf := standard.New(n, eps)
+
+	keys := make([]*big.Int, int(n))
+	for i := 0; i < int(n); i++ {
+		r, e := rand.Int(rand.Reader, big.NewInt(max))
+		if e != nil {
+			log.Fatalln(e)
+		}
+		keys[i] = r
+		sbf = sbf.Add(r.Bytes())
+	}
+
+	owner, e := NewOwner(keySize)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	eb, e := owner.Encrypt(sbf.(*standard.StandardBloom), ModeCA, maxConc)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	if eb.Hash() == sbf.(*standard.StandardBloom).Hash() {
+		log.Fatalln("Encrypted filter should not share the plaintext filter's hasher")
+	}
+	data, e := eb.MarshalBinary()
+	if e != nil {
+		log.Fatalln(e)
+	}
+
+	evaluator, e := NewPublic(data)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	rs := checkBatch(evaluator, keys)
+	if _, e := evaluator.Decrypt(rs, maxConc); e != ErrNoPrivateKey {
+		log.Fatalln("Evaluator should not be able to decrypt")
+	}
+
+	ca, e := rs.Ciphertexts()
+	if e != nil {
+		log.Fatalln(e)
+	}
+	ptxts, e := owner.Decrypt(ca, maxConc)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	if len(ptxts) != len(ca) {
+		log.Fatalln("Wrong number of decrypted results")
+	}
+	for _, v := range ptxts {
+		if v.M0.Sign() != 0 || v.M1 != nil {
+			log.Fatalln("Should be encryption of zero [owner]")
+		}
+	}
+}
+
+func TestCardinality(t *testing.T) {
+	sbf := standard.New(n, eps)
+	for i := 1; i <= int(n); i++ {
+		sbf = sbf.Add(big.NewInt(int64(i)).Bytes())
+	}
+
+	// Half of the queried elements are in the filter
+	eblof := newEncBloom(sbf, ModeCA)
+	keys := [][]byte{}
+	for i := int(n)/2 + 1; i <= int(n)+int(n)/2; i++ {
+		keys = append(keys, big.NewInt(int64(i)).Bytes())
+	}
+	rs, e := eblof.CheckBatch(context.Background(), keys, maxConc)
+	if e != nil {
+		log.Fatalln(e)
+	}
+
+	inter, union, e := eblof.Cardinality(rs, int(n), maxConc)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	if inter != int(n)/2 || union != int(n)+int(n)/2 {
+		log.Fatalln("Wrong cardinalities:", inter, union)
+	}
+	if _, _, e := eblof.Cardinality(rs, int(n), 0); e != ErrConcurrency {
+		log.Fatalln("Expected concurrency error, got", e)
+	}
+}
+
+// EncryptFast must decrypt to the same bits as Encrypt, with no two
+// ciphertexts equal
+func TestEncryptFast(t *testing.T) {
+	sbf := standard.New(n, eps)
+	for i := 1; i <= int(n); i++ {
+		sbf = sbf.Add(big.NewInt(int64(i)).Bytes())
+	}
+	_, _, _, _, _, bits := sbf.(*standard.StandardBloom).GetParams()
+
+	for _, s := range []Scheme{&Paillier{}, &ElGamal{}} {
+		owner, e := NewOwnerWithScheme(s, keySize)
+		if e != nil {
+			log.Fatalln(e)
+		}
+		eb, e := owner.EncryptFast(sbf.(*standard.StandardBloom), ModeCA, maxConc)
+		if e != nil {
+			log.Fatalln(e)
+		}
+
+		seen := make(map[string]bool)
+		for i, c := range eb.ebf {
+			if seen[c.String()] {
+				log.Fatalln("Ciphertexts were not rerandomized")
+			}
+			seen[c.String()] = true
+
+			m, e := owner.Scheme().Decrypt(c)
+			if e != nil {
+				log.Fatalln(e)
+			}
+			if (m.Sign() == 0) != bits.Get(i) {
+				log.Fatalln("Wrong bit", i, "in fast encryption")
+			}
+		}
+	}
+}
+
+func BenchmarkEncryptFast(b *testing.B) {
+	sbf := standard.New(20, eps).(*standard.StandardBloom)
+	for _, bits := range []int{1024, 2048, 3072} {
+		owner, e := NewOwner(bits)
+		if e != nil {
+			log.Fatalln(e)
+		}
+
+		b.Run(fmt.Sprintf("%d/encrypt", bits), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, e := owner.Encrypt(sbf, ModePSI, runtime.NumCPU()); e != nil {
+					log.Fatalln(e)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("%d/fast", bits), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, e := owner.EncryptFast(sbf, ModePSI, runtime.NumCPU()); e != nil {
+					log.Fatalln(e)
+				}
+			}
+		})
+	}
+}
+
+func TestDecryptBatch(t *testing.T) {
+	owner, e := NewOwner(keySize)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	s := owner.Scheme()
+
+	ca := make([][]*big.Int, 4*maxConc)
+	for i := range ca {
+		c0, _ := s.Encrypt(big.NewInt(int64(i)))
+		c1, _ := s.Encrypt(big.NewInt(int64(2 * i)))
+		ca[i] = []*big.Int{c0, c1}
+	}
+	ptxts, e := owner.Decrypt(ca, maxConc)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	if _, e := owner.Decrypt(ca, 0); e != ErrConcurrency {
+		log.Fatalln("Expected concurrency error, got", e)
+	}
+	for i, v := range ptxts {
+		if v.M0.Int64() != int64(i) || v.M1.Int64() != int64(2*i) {
+			log.Fatalln("Batch decryption out of order at", i)
+		}
+	}
+}
+
+// PSI over a partitioned filter, with the layout carried by the wire format
+func TestPartitioned(t *testing.T) {
+	pbf := partitioned.New(n, eps)
+	for i := 1; i <= int(n); i++ {
+		pbf = pbf.Add(big.NewInt(int64(i)).Bytes())
+	}
+
+	owner, e := NewOwner(keySize)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	eb, e := owner.Encrypt(pbf.(*partitioned.PartitionedBloom), ModePSI, maxConc)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	data, e := eb.MarshalBinary()
+	if e != nil {
+		log.Fatalln(e)
+	}
+	evaluator, e := NewPublic(data)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	if evaluator.layout != bloom.LayoutPartitioned {
+		log.Fatalln("Layout lost in serialization")
+	}
+
+	keys := [][]byte{}
+	for i := int(n)/2 + 1; i <= int(n)+int(n)/2; i++ {
+		keys = append(keys, big.NewInt(int64(i)).Bytes())
+	}
+	rs, e := evaluator.CheckBatch(context.Background(), keys, maxConc)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	ptxts, e := owner.Decrypt(rs.ciphertexts(), maxConc)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	for i, v := range ptxts {
+		in := new(big.Int).SetBytes(keys[i]).Int64() <= int64(n)
+		if in != (v.M1.Sign() == 0) || (in && v.M0.Cmp(new(big.Int).SetBytes(keys[i])) != 0) {
+			log.Fatalln("Wrong intersection result for", keys[i])
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package encbf
+
+import (
+	"bytes"
+	"crypto/rand"
+	"github.com/mcornejo/go-go-gadget-paillier"
+	"io"
+	"math/big"
+)
+
+var one = big.NewInt(1)
+
+// Paillier is the default Scheme. Homomorphic operations use
+// go-go-gadget-paillier, keys are generated here so that the key holder can
+// use the factorization of N to compute randomizers faster.
+type Paillier struct {
+	pub  *paillier.PublicKey // public key for encryption
+	priv *paillierKey        // private key for decryption, nil if public-only
+	pool *RandPool           // optional source of precomputed r^N
+}
+
+// paillierKey holds the factors of N and values derived from them
+type paillierKey struct {
+	p, q   *big.Int
+	pp, qq *big.Int // p^2, q^2
+	ep, eq *big.Int // N mod p(p-1), N mod q(q-1), CRT exponents for r^N
+	qqInv  *big.Int // (q^2)^-1 mod p^2
+	pm1    *big.Int // p-1
+	qm1    *big.Int // q-1
+	hp, hq *big.Int // L_p(g^(p-1) mod p^2)^-1 mod p, likewise for q
+	qInv   *big.Int // q^-1 mod p
+}
+
+func newPaillierKey(p, q *big.Int) *paillierKey {
+	n := new(big.Int).Mul(p, q)
+	pm1 := new(big.Int).Sub(p, one)
+	qm1 := new(big.Int).Sub(q, one)
+	pp := new(big.Int).Mul(p, p)
+	qq := new(big.Int).Mul(q, q)
+
+	// With g = N+1, g^(p-1) mod p^2 = 1 + (p-1)N, so L_p(g^(p-1)) = (p-1)q
+	hp := new(big.Int).Mul(pm1, q)
+	hp.ModInverse(hp.Mod(hp, p), p)
+	hq := new(big.Int).Mul(qm1, p)
+	hq.ModInverse(hq.Mod(hq, q), q)
+
+	return &paillierKey{
+		p:     p,
+		q:     q,
+		pp:    pp,
+		qq:    qq,
+		ep:    new(big.Int).Mod(n, new(big.Int).Mul(p, pm1)),
+		eq:    new(big.Int).Mod(n, new(big.Int).Mul(q, qm1)),
+		qqInv: new(big.Int).ModInverse(qq, pp),
+		pm1:   pm1,
+		qm1:   qm1,
+		hp:    hp,
+		hq:    hq,
+		qInv:  new(big.Int).ModInverse(q, p),
+	}
+}
+
+var _ Scheme = (*Paillier)(nil)
+
+func (this *Paillier) ID() SchemeID {
+	return SchemePaillier
+}
+
+func (this *Paillier) KeyGen(random io.Reader, bits int) (Scheme, error) {
+	var p, q *big.Int
+	for p == nil || p.Cmp(q) == 0 {
+		var e error
+		if p, e = rand.Prime(random, bits/2); e != nil {
+			return nil, e
+		}
+		if q, e = rand.Prime(random, bits/2); e != nil {
+			return nil, e
+		}
+	}
+
+	return &Paillier{pub: paillierPublicKey(new(big.Int).Mul(p, q)), priv: newPaillierKey(p, q)}, nil
+}
+
+// SetPool makes Encrypt and Rerandomize draw their randomizers from pool
+func (this *Paillier) SetPool(pool *RandPool) error {
+	if pool.pub.N.Cmp(this.pub.N) != 0 {
+		return ErrPoolKey
+	}
+	this.pool = pool
+
+	return nil
+}
+
+func (this *Paillier) Encrypt(m *big.Int) (*big.Int, error) {
+	if m.Sign() < 0 || this.pub.N.Cmp(m) < 1 {
+		return nil, ErrMessageTooLong
+	}
+	rn, e := this.randN()
+	if e != nil {
+		return nil, e
+	}
+
+	// c = g^m * r^n mod n^2 = ((m*n+1) mod n^2) * r^n mod n^2
+	c := new(big.Int).Mul(m, this.pub.N)
+	c.Add(c, one)
+	c.Mul(c, rn)
+
+	return c.Mod(c, this.pub.NSquared), nil
+}
+
+func (this *Paillier) Decrypt(c *big.Int) (*big.Int, error) {
+	if this.priv == nil {
+		return nil, ErrNoPrivateKey
+	}
+	if c.Sign() <= 0 || this.pub.NSquared.Cmp(c) < 1 {
+		return nil, ErrMessageTooLong
+	}
+
+	// m mod p = L_p(c^(p-1) mod p^2) * hp mod p, where L_p(u) = (u - 1) / p,
+	// likewise mod q, then recombined
+	k := this.priv
+	mp := new(big.Int).Exp(c, k.pm1, k.pp)
+	mp.Sub(mp, one).Div(mp, k.p).Mul(mp, k.hp).Mod(mp, k.p)
+	mq := new(big.Int).Exp(c, k.qm1, k.qq)
+	mq.Sub(mq, one).Div(mq, k.q).Mul(mq, k.hq).Mod(mq, k.q)
+
+	mp.Sub(mp, mq).Mul(mp, k.qInv).Mod(mp, k.p)
+
+	return mp.Mul(mp, k.q).Add(mp, mq), nil
+}
+
+func (this *Paillier) AddCipher(c1, c2 *big.Int) *big.Int {
+	return new(big.Int).SetBytes(paillier.AddCipher(this.pub, c1.Bytes(), c2.Bytes()))
+}
+
+func (this *Paillier) ScalarMul(c, k *big.Int) *big.Int {
+	return new(big.Int).SetBytes(paillier.Mul(this.pub, c.Bytes(), k.Bytes()))
+}
+
+// Rerandomize multiplies c by a fresh encryption of zero, r^N mod N^2
+func (this *Paillier) Rerandomize(c *big.Int) (*big.Int, error) {
+	rn, e := this.randN()
+	if e != nil {
+		return nil, e
+	}
+	out := new(big.Int).Mul(c, rn)
+
+	return out.Mod(out, this.pub.NSquared), nil
+}
+
+// CheckCiphertext accepts c in [1, N^2) coprime to N
+func (this *Paillier) CheckCiphertext(c *big.Int) error {
+	if c.Sign() <= 0 || this.pub.NSquared.Cmp(c) < 1 || new(big.Int).GCD(nil, nil, c, this.pub.N).Cmp(one) != 0 {
+		return ErrInvalidEncoding
+	}
+
+	return nil
+}
+
+func (this *Paillier) PlaintextModulus() *big.Int {
+	return this.pub.N
+}
+
+// Public strips the private key but keeps the randomness pool
+func (this *Paillier) Public() Scheme {
+	return &Paillier{pub: this.pub, pool: this.pool}
+}
+
+// MarshalPublicKey encodes N, the other public values are derived from it
+func (this *Paillier) MarshalPublicKey() []byte {
+	return this.pub.N.Bytes()
+}
+
+func (this *Paillier) randN() (*big.Int, error) {
+	if this.pool != nil {
+		return this.pool.Get()
+	}
+	if this.priv == nil {
+		return randN(this.pub)
+	}
+
+	// With the factors, r^N is computed mod p^2 and q^2 with exponents
+	// reduced by phi(p^2) and phi(q^2), then recombined
+	r, e := rand.Int(rand.Reader, this.pub.N)
+	if e != nil {
+		return nil, e
+	}
+	k := this.priv
+	rp := new(big.Int).Exp(r, k.ep, k.pp)
+	rq := new(big.Int).Exp(r, k.eq, k.qq)
+	rp.Sub(rp, rq).Mul(rp, k.qqInv).Mod(rp, k.pp)
+
+	return rp.Mul(rp, k.qq).Add(rp, rq), nil
+}
+
+func (this *Paillier) UnmarshalPublicKey(data []byte) (Scheme, error) {
+	N := new(big.Int).SetBytes(data)
+	if N.Sign() <= 0 {
+		return nil, ErrInvalidEncoding
+	}
+
+	return &Paillier{pub: paillierPublicKey(N)}, nil
+}
+
+func paillierPublicKey(N *big.Int) *paillier.PublicKey {
+	return &paillier.PublicKey{
+		N:        N,
+		G:        new(big.Int).Add(N, one), // g = n + 1
+		NSquared: new(big.Int).Mul(N, N),
+	}
+}
+
+var _ PrivateKeyMarshaler = (*Paillier)(nil)
+
+// MarshalPrivateKey encodes the factors p and q of N, length-prefixed
+func (this *Paillier) MarshalPrivateKey() ([]byte, error) {
+	if this.priv == nil {
+		return nil, ErrNoPrivateKey
+	}
+	var buf bytes.Buffer
+	writeInt(&buf, this.priv.p)
+	writeInt(&buf, this.priv.q)
+
+	return buf.Bytes(), nil
+}
+
+func (this *Paillier) UnmarshalPrivateKey(data []byte) (Scheme, error) {
+	r := bytes.NewReader(data)
+	p, e := readInt(r)
+	if e != nil {
+		return nil, ErrInvalidEncoding
+	}
+	q, e := readInt(r)
+	if e != nil || r.Len() != 0 || p.Cmp(one) <= 0 || q.Cmp(one) <= 0 || p.Cmp(q) == 0 {
+		return nil, ErrInvalidEncoding
+	}
+	if !p.ProbablyPrime(20) || !q.ProbablyPrime(20) {
+		return nil, ErrInvalidEncoding
+	}
+
+	return &Paillier{pub: paillierPublicKey(new(big.Int).Mul(p, q)), priv: newPaillierKey(p, q)}, nil
+}
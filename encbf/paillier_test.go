@@ -0,0 +1,53 @@
+package encbf
+
+import (
+	"crypto/rand"
+	"log"
+	"math/big"
+	"testing"
+)
+
+// Check the homomorphic properties EncBloom relies on
+func testScheme(s Scheme) {
+	priv, e := s.KeyGen(rand.Reader, keySize)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	pub, e := s.UnmarshalPublicKey(priv.Public().MarshalPublicKey())
+	if e != nil {
+		log.Fatalln(e)
+	}
+
+	a, b := big.NewInt(3), big.NewInt(4)
+	ca, e := pub.Encrypt(a)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	cb, e := pub.Encrypt(b)
+	if e != nil {
+		log.Fatalln(e)
+	}
+
+	if _, e := pub.Decrypt(ca); e != ErrNoPrivateKey {
+		log.Fatalln("Public-only scheme should not decrypt")
+	}
+	if pub.CheckCiphertext(ca) != nil || pub.CheckCiphertext(new(big.Int).Lsh(ca, 1024)) != ErrInvalidEncoding {
+		log.Fatalln("Ciphertext validation failed")
+	}
+
+	cr, e := pub.Rerandomize(pub.AddCipher(pub.ScalarMul(ca, big.NewInt(5)), cb))
+	if e != nil {
+		log.Fatalln(e)
+	}
+	m, e := priv.Decrypt(cr)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	if m.Cmp(big.NewInt(19)) != 0 {
+		log.Fatalln("Expected 5*3 + 4, got", m)
+	}
+}
+
+func TestPaillier(t *testing.T) {
+	testScheme(&Paillier{})
+}
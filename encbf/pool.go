@@ -0,0 +1,148 @@
+package encbf
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"github.com/mcornejo/go-go-gadget-paillier"
+	"io"
+	"math/big"
+	"sync"
+)
+
+var ErrPoolKey = errors.New("encbf: randomness pool belongs to a different public key")
+
+// RandPool precomputes the Paillier randomizers r^N mod N^2 that dominate
+// the cost of Encrypt and Rerandomize. Background goroutines keep a buffer
+// of values filled, and Get computes a value inline when the buffer is empty
+// so that a drained pool is never slower than no pool.
+//
+// Every value must be used at most once. Save removes the values it writes
+// from the pool, and a saved file must be loaded once and then discarded.
+type RandPool struct {
+	pub  *paillier.PublicKey // key the randomizers belong to
+	rs   chan *big.Int       // precomputed r^N mod N^2
+	quit chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+// NewRandPool starts workers goroutines filling a buffer of size randomizers
+// for the public key of s. Call Close to stop them.
+func NewRandPool(s *Paillier, size, workers int) *RandPool {
+	pool := &RandPool{
+		pub:  s.pub,
+		rs:   make(chan *big.Int, size),
+		quit: make(chan struct{}),
+	}
+
+	pool.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go pool.fill()
+	}
+
+	return pool
+}
+
+func (this *RandPool) fill() {
+	defer this.wg.Done()
+	for {
+		rn, e := randN(this.pub)
+		if e != nil {
+			return
+		}
+		select {
+		case this.rs <- rn:
+		case <-this.quit:
+			return
+		}
+	}
+}
+
+// Get returns a fresh randomizer, from the buffer if one is available
+func (this *RandPool) Get() (*big.Int, error) {
+	select {
+	case rn := <-this.rs:
+		return rn, nil
+	default:
+		return randN(this.pub)
+	}
+}
+
+// Len is the number of randomizers currently buffered
+func (this *RandPool) Len() int {
+	return len(this.rs)
+}
+
+// Close stops the background workers. Buffered values remain available.
+func (this *RandPool) Close() {
+	this.once.Do(func() { close(this.quit) })
+	this.wg.Wait()
+}
+
+// Save drains the buffered randomizers into w as a uint32 count followed by
+// length-prefixed values, so that precomputation can happen ahead of a run.
+func (this *RandPool) Save(w io.Writer) error {
+	var rs []*big.Int
+	for i, l := 0, len(this.rs); i < l; i++ {
+		select {
+		case rn := <-this.rs:
+			rs = append(rs, rn)
+		default:
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	binary.Write(bw, binary.BigEndian, uint32(len(rs)))
+	for _, rn := range rs {
+		writeInt(bw, rn)
+	}
+
+	return bw.Flush()
+}
+
+// Load adds randomizers written by Save to the pool, as many as fit in the
+// buffer. The rest are dropped so they cannot be reused.
+func (this *RandPool) Load(r io.Reader) error {
+	br := bufio.NewReader(r)
+	var l uint32
+	if e := binary.Read(br, binary.BigEndian, &l); e != nil {
+		return e
+	}
+
+	for i := uint32(0); i < l; i++ {
+		var size uint32
+		if e := binary.Read(br, binary.BigEndian, &size); e != nil {
+			return e
+		}
+		if size > uint32(len(this.pub.NSquared.Bytes())) {
+			return ErrInvalidEncoding
+		}
+		b := make([]byte, size)
+		if _, e := io.ReadFull(br, b); e != nil {
+			return e
+		}
+		rn := new(big.Int).SetBytes(b)
+		if rn.Sign() == 0 || rn.Cmp(this.pub.NSquared) >= 0 {
+			return ErrInvalidEncoding
+		}
+
+		select {
+		case this.rs <- rn:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// r^N mod N^2 for a random r in Z_N
+func randN(pub *paillier.PublicKey) (*big.Int, error) {
+	r, e := rand.Int(rand.Reader, pub.N)
+	if e != nil {
+		return nil, e
+	}
+
+	return r.Exp(r, pub.N, pub.NSquared), nil
+}
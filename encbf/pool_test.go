@@ -0,0 +1,120 @@
+package encbf
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"github.com/alxdavids/bloom-filter/standard"
+	"log"
+	"math/big"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func newPooledOwner(bits, size int) (*Owner, *RandPool) {
+	owner, e := NewOwner(bits)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	pool := NewRandPool(owner.Scheme().(*Paillier), size, runtime.NumCPU())
+	if e := owner.Scheme().(*Paillier).SetPool(pool); e != nil {
+		log.Fatalln(e)
+	}
+
+	return owner, pool
+}
+
+func waitFull(pool *RandPool, size int) {
+	for pool.Len() < size {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRandPool(t *testing.T) {
+	size := 64
+	owner, pool := newPooledOwner(keySize, size)
+	defer pool.Close()
+	waitFull(pool, size)
+
+	s := owner.Scheme()
+	for i := 0; i < 2*size; i++ {
+		m, _ := rand.Int(rand.Reader, big.NewInt(max))
+		c, e := s.Encrypt(m)
+		if e != nil {
+			log.Fatalln(e)
+		}
+		c, e = s.Rerandomize(c)
+		if e != nil {
+			log.Fatalln(e)
+		}
+		d, e := s.Decrypt(c)
+		if e != nil {
+			log.Fatalln(e)
+		}
+		if d.Cmp(m) != 0 {
+			log.Fatalln("Pooled encryption does not decrypt correctly")
+		}
+	}
+
+	// Saving drains the buffer, loading refills it
+	waitFull(pool, size)
+	pool.Close()
+	var buf bytes.Buffer
+	if e := pool.Save(&buf); e != nil {
+		log.Fatalln(e)
+	}
+	saved := buf.Len()
+	if pool.Len() != 0 {
+		log.Fatalln("Save should drain the pool")
+	}
+	if e := pool.Load(&buf); e != nil {
+		log.Fatalln(e)
+	}
+	if saved <= 4 || pool.Len() != size {
+		log.Fatalln("Load did not refill the pool")
+	}
+
+	other, e := NewOwner(keySize)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	if e := other.Scheme().(*Paillier).SetPool(pool); e != ErrPoolKey {
+		log.Fatalln("Expected pool key error, got", e)
+	}
+}
+
+// Encryption of the filter with and without a prefilled pool, the pool is
+// refilled outside the timer as it would be ahead of a run
+func BenchmarkEncryptPool(b *testing.B) {
+	for _, fn := range []uint{100, 1000} {
+		sbf := standard.New(fn, eps).(*standard.StandardBloom)
+		_, L, _, _, _, _ := sbf.GetParams()
+
+		b.Run(fmt.Sprintf("L=%d/nopool", L), func(b *testing.B) {
+			owner, e := NewOwner(1024)
+			if e != nil {
+				log.Fatalln(e)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, e := owner.Encrypt(sbf, ModePSI, runtime.NumCPU()); e != nil {
+					log.Fatalln(e)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("L=%d/pool", L), func(b *testing.B) {
+			owner, pool := newPooledOwner(1024, int(L))
+			defer pool.Close()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				waitFull(pool, int(L))
+				b.StartTimer()
+				if _, e := owner.Encrypt(sbf, ModePSI, runtime.NumCPU()); e != nil {
+					log.Fatalln(e)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package encbf
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"math/big"
+	"sync"
+)
+
+// SchemeID identifies a Scheme in the wire format
+type SchemeID uint8
+
+const (
+	SchemePaillier SchemeID = iota + 1
+	SchemeElGamal
+)
+
+// Scheme is an additively homomorphic public-key encryption scheme. A value
+// returned by KeyGen holds the private key, Public strips it. Plaintexts are
+// integers modulo PlaintextModulus and ciphertexts are encoded as integers so
+// that EncBloom can store and transmit them uniformly.
+type Scheme interface {
+	ID() SchemeID
+	// KeyGen returns a new instance holding a fresh keypair of the given size
+	KeyGen(random io.Reader, bits int) (Scheme, error)
+	Encrypt(m *big.Int) (*big.Int, error)
+	// Decrypt returns ErrNoPrivateKey on a public-only instance
+	Decrypt(c *big.Int) (*big.Int, error)
+	// AddCipher returns an encryption of m1 + m2
+	AddCipher(c1, c2 *big.Int) *big.Int
+	// ScalarMul returns an encryption of k * m
+	ScalarMul(c, k *big.Int) *big.Int
+	// Rerandomize returns a fresh encryption of the same plaintext
+	Rerandomize(c *big.Int) (*big.Int, error)
+	// CheckCiphertext returns ErrInvalidEncoding if c is not a ciphertext
+	// under this key. Ciphertexts read from outside must pass it before
+	// AddCipher or ScalarMul.
+	CheckCiphertext(c *big.Int) error
+	PlaintextModulus() *big.Int
+	Public() Scheme
+	MarshalPublicKey() []byte
+	// UnmarshalPublicKey returns a public-only instance for the encoded key
+	UnmarshalPublicKey(data []byte) (Scheme, error)
+}
+
+// ZeroTester is implemented by schemes that can tell whether a ciphertext
+// encrypts zero without recovering the plaintext. Schemes that cannot fully
+// decrypt, such as ElGamal, only support ModeCA.
+type ZeroTester interface {
+	IsZero(c *big.Int) (bool, error)
+}
+
+// PrivateKeyMarshaler is implemented by schemes whose private key can be
+// stored, so that the Owner can encrypt and decrypt in separate runs
+type PrivateKeyMarshaler interface {
+	MarshalPrivateKey() ([]byte, error)
+	UnmarshalPrivateKey(data []byte) (Scheme, error)
+}
+
+var ErrSchemeExists = errors.New("encbf: a scheme is already registered under this id")
+
+// schemesMu guards schemes, which RegisterScheme may change at any time
+var schemesMu sync.RWMutex
+
+// Schemes that NewPublic can restore, keyed by ID
+var schemes = map[SchemeID]Scheme{
+	SchemePaillier: &Paillier{},
+	SchemeElGamal:  &ElGamal{},
+}
+
+// RegisterScheme makes an additional Scheme available to NewPublic. Ids
+// already in use are refused with ErrSchemeExists.
+func RegisterScheme(s Scheme) error {
+	schemesMu.Lock()
+	defer schemesMu.Unlock()
+	if _, ok := schemes[s.ID()]; ok {
+		return ErrSchemeExists
+	}
+	schemes[s.ID()] = s
+
+	return nil
+}
+
+func schemeByID(id SchemeID) (Scheme, bool) {
+	schemesMu.RLock()
+	defer schemesMu.RUnlock()
+	s, ok := schemes[id]
+
+	return s, ok
+}
+
+// Random plaintext in [0, PlaintextModulus) for blinding
+func randPlaintext(s Scheme) (*big.Int, error) {
+	return rand.Int(rand.Reader, s.PlaintextModulus())
+}
@@ -0,0 +1,40 @@
+package encbf
+
+import (
+	"log"
+	"testing"
+)
+
+// renamed is Paillier under another id
+type renamed struct {
+	Paillier
+	id SchemeID
+}
+
+func (this *renamed) ID() SchemeID {
+	return this.id
+}
+
+func TestRegisterScheme(t *testing.T) {
+	if e := RegisterScheme(&renamed{id: SchemePaillier}); e != ErrSchemeExists {
+		log.Fatalln("Expected exists error for a built-in id, got", e)
+	}
+
+	if e := RegisterScheme(&renamed{id: 200}); e != nil {
+		log.Fatalln(e)
+	}
+	defer func() {
+		schemesMu.Lock()
+		delete(schemes, 200)
+		schemesMu.Unlock()
+	}()
+	if s, ok := schemeByID(200); !ok || s.ID() != 200 {
+		log.Fatalln("Registered scheme not found")
+	}
+	if e := RegisterScheme(&renamed{id: 200}); e != ErrSchemeExists {
+		log.Fatalln("Expected exists error, got", e)
+	}
+	if s, _ := schemeByID(SchemePaillier); s.ID() != SchemePaillier {
+		log.Fatalln("Built-in scheme was replaced")
+	}
+}
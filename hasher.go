@@ -0,0 +1,203 @@
+package bloom
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"github.com/reusee/mmh3"
+	"hash"
+	"hash/fnv"
+	"log"
+	"sync"
+)
+
+var (
+	ErrHasher         = errors.New("bloom: unknown hasher")
+	ErrHasherKey      = errors.New("bloom: keyed hashers need a key and others must not be given one")
+	ErrHasherMismatch = errors.New("bloom: filters use different hashers or hasher keys")
+	ErrHasherExists   = errors.New("bloom: a hasher is already registered under this id")
+)
+
+type hasher struct {
+	name  string
+	keyed bool
+	new   func(key []byte) hash.Hash
+}
+
+// hashersMu guards hashers, which RegisterHasher may change at any time
+var hashersMu sync.RWMutex
+
+var hashers = map[HasherID]hasher{
+	HasherMMH3:   {"mmh3-128", false, func([]byte) hash.Hash { return mmh3.New128() }},
+	HasherSHA256: {"sha256", false, func([]byte) hash.Hash { return sha256.New() }},
+	HasherHMACSHA256: {"hmac-sha256", true, func(key []byte) hash.Hash {
+		return hmac.New(sha256.New, key)
+	}},
+	HasherFNV128a: {"fnv128a", false, func([]byte) hash.Hash { return fnv.New128a() }},
+}
+
+// RegisterHasher makes a hash function available to NewHasher under id. The
+// digest should be at least 16 bytes long (see Indices). Both parties to a
+// protocol must register the same function under the same id. Ids already
+// in use and HasherCustom are refused with ErrHasherExists.
+func RegisterHasher(id HasherID, name string, keyed bool, new func(key []byte) hash.Hash) error {
+	hashersMu.Lock()
+	defer hashersMu.Unlock()
+	if _, ok := hashers[id]; ok || id == HasherCustom {
+		return ErrHasherExists
+	}
+	hashers[id] = hasher{name, keyed, new}
+
+	return nil
+}
+
+// NewHasher returns a fresh instance of the hash function registered as id.
+// key is required by keyed hashers, which make the bits a key sets
+// unpredictable to anyone without the key, and must be nil otherwise.
+func NewHasher(id HasherID, key []byte) (hash.Hash, error) {
+	hashersMu.RLock()
+	hs, ok := hashers[id]
+	hashersMu.RUnlock()
+	if !ok || id == HasherCustom {
+		return nil, ErrHasher
+	}
+	if hs.keyed != (len(key) > 0) {
+		return nil, ErrHasherKey
+	}
+
+	return hs.new(key), nil
+}
+
+// Keyed reports whether the hasher registered as id needs a key
+func (id HasherID) Keyed() bool {
+	hashersMu.RLock()
+	defer hashersMu.RUnlock()
+
+	return hashers[id].keyed
+}
+
+func (id HasherID) String() string {
+	if id == HasherCustom {
+		return "custom"
+	}
+	hashersMu.RLock()
+	defer hashersMu.RUnlock()
+	if hs, ok := hashers[id]; ok {
+		return hs.name
+	}
+
+	return "unknown"
+}
+
+// HasherByName looks up the id of a registered hasher
+func HasherByName(name string) (HasherID, error) {
+	hashersMu.RLock()
+	defer hashersMu.RUnlock()
+	for id, hs := range hashers {
+		if hs.name == name {
+			return id, nil
+		}
+	}
+
+	return HasherCustom, ErrHasher
+}
+
+// KeyCheck is a short fingerprint of a hasher key that is stored with a
+// filter, so that parties using different keys are detected without revealing
+// the key. It is all zero for unkeyed hashers.
+func KeyCheck(key []byte) [8]byte {
+	var kc [8]byte
+	if len(key) > 0 {
+		m := hmac.New(sha256.New, key)
+		m.Write([]byte("yabf hasher key check"))
+		copy(kc[:], m.Sum(nil))
+	}
+
+	return kc
+}
+
+// CheckHasher validates that a filter recorded with hasher id and key check
+// kc can be used with key
+func CheckHasher(id HasherID, kc [8]byte, key []byte) error {
+	if _, e := NewHasher(id, key); e != nil {
+		return e
+	}
+	want := KeyCheck(key)
+	if !bytes.Equal(kc[:], want[:]) {
+		return ErrHasherMismatch
+	}
+
+	return nil
+}
+
+// Hasher is the hash function of a filter together with what is needed to
+// serialize and recreate it. Filters embed it for SetHasher, UseHasher,
+// HasherID and HasherKeyCheck.
+type Hasher struct {
+	h    hash.Hash // hash function used for query and storage
+	hid  HasherID  // identifier of h for serialization
+	hkey []byte    // key of a keyed hasher
+}
+
+// DefaultHasher is the hasher of a new filter, HasherMMH3
+func DefaultHasher() Hasher {
+	return Hasher{h: mmh3.New128(), hid: HasherMMH3}
+}
+
+// SetHasher replaces the hash function, which must produce at least 16
+// bytes. Filters using it cannot be serialized.
+func (this *Hasher) SetHasher(h hash.Hash) {
+	this.h = h
+	this.hid = HasherCustom
+	this.hkey = nil
+}
+
+// UseHasher selects the registered hash function id, key is required by
+// keyed hashers and must be nil otherwise
+func (this *Hasher) UseHasher(id HasherID, key []byte) error {
+	h, e := NewHasher(id, key)
+	if e != nil {
+		return e
+	}
+	this.h = h
+	this.hid = id
+	this.hkey = key
+
+	return nil
+}
+
+// Hash returns the hash function in use
+func (this *Hasher) Hash() hash.Hash {
+	return this.h
+}
+
+func (this *Hasher) HasherID() HasherID {
+	return this.hid
+}
+
+// HasherKeyCheck is the fingerprint of the hasher key, see KeyCheck
+func (this *Hasher) HasherKeyCheck() [8]byte {
+	return KeyCheck(this.hkey)
+}
+
+// CloneHasher returns a Hasher for another filter that hashes the same way.
+// A registered hasher gets a fresh instance, a custom one is shared.
+func (this *Hasher) CloneHasher() Hasher {
+	if this.hid == HasherCustom {
+		return *this
+	}
+	h, _ := NewHasher(this.hid, this.hkey)
+
+	return Hasher{h: h, hid: this.hid, hkey: this.hkey}
+}
+
+// Sum returns the digest of key
+func (this *Hasher) Sum(key []byte) []byte {
+	this.h.Reset()
+	if _, e := this.h.Write(key); e != nil {
+		log.Println(e)
+	}
+
+	return this.h.Sum(nil)
+}
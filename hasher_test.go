@@ -0,0 +1,104 @@
+package bloom
+
+import (
+	"hash"
+	"hash/fnv"
+	"log"
+	"testing"
+)
+
+func TestHashers(t *testing.T) {
+	key := []byte("secret")
+	for _, id := range []HasherID{HasherMMH3, HasherSHA256, HasherHMACSHA256, HasherFNV128a} {
+		var k []byte
+		if id.Keyed() {
+			k = key
+		}
+		h, e := NewHasher(id, k)
+		if e != nil {
+			log.Fatalln(e)
+		}
+		if h.Size() < 16 {
+			log.Fatalln("Digest of", id, "is too short for Indices")
+		}
+		if byName, e := HasherByName(id.String()); e != nil || byName != id {
+			log.Fatalln("Lookup by name failed for", id)
+		}
+
+		// Deterministic across instances
+		h2, _ := NewHasher(id, k)
+		h.Write([]byte("x"))
+		h2.Write([]byte("x"))
+		if string(h.Sum(nil)) != string(h2.Sum(nil)) {
+			log.Fatalln("Hasher is not deterministic:", id)
+		}
+	}
+
+	if _, e := NewHasher(HasherCustom, nil); e != ErrHasher {
+		log.Fatalln("Expected hasher error, got", e)
+	}
+	if _, e := NewHasher(HasherHMACSHA256, nil); e != ErrHasherKey {
+		log.Fatalln("Expected key error, got", e)
+	}
+	if _, e := NewHasher(HasherMMH3, key); e != ErrHasherKey {
+		log.Fatalln("Expected key error, got", e)
+	}
+
+	// Different keys give different digests and fingerprints
+	a, _ := NewHasher(HasherHMACSHA256, key)
+	b, _ := NewHasher(HasherHMACSHA256, []byte("other"))
+	a.Write([]byte("x"))
+	b.Write([]byte("x"))
+	if string(a.Sum(nil)) == string(b.Sum(nil)) {
+		log.Fatalln("Keyed hasher ignores its key")
+	}
+	if e := CheckHasher(HasherHMACSHA256, KeyCheck(key), key); e != nil {
+		log.Fatalln(e)
+	}
+	if e := CheckHasher(HasherHMACSHA256, KeyCheck(key), []byte("other")); e != ErrHasherMismatch {
+		log.Fatalln("Expected mismatch, got", e)
+	}
+	if KeyCheck(nil) != [8]byte{} {
+		log.Fatalln("Unkeyed fingerprint should be zero")
+	}
+
+	fnv128 := func([]byte) hash.Hash { return fnv.New128() }
+	if e := RegisterHasher(200, "fnv128", false, fnv128); e != nil {
+		log.Fatalln(e)
+	}
+	defer func() {
+		hashersMu.Lock()
+		delete(hashers, 200)
+		hashersMu.Unlock()
+	}()
+	if id, e := HasherByName("fnv128"); e != nil || id != 200 {
+		log.Fatalln("Registered hasher not found")
+	}
+	for _, id := range []HasherID{200, HasherMMH3, HasherCustom} {
+		if e := RegisterHasher(id, "fnv128", false, fnv128); e != ErrHasherExists {
+			log.Fatalln("Expected exists error for", id, "got", e)
+		}
+	}
+}
+
+func TestHasherClone(t *testing.T) {
+	hs := DefaultHasher()
+	if e := hs.UseHasher(HasherHMACSHA256, []byte("secret")); e != nil {
+		log.Fatalln(e)
+	}
+	c := hs.CloneHasher()
+	if c.Hash() == hs.Hash() || c.HasherID() != hs.HasherID() || c.HasherKeyCheck() != hs.HasherKeyCheck() {
+		log.Fatalln("Registered hasher should be cloned as a fresh instance")
+	}
+	if string(c.Sum([]byte("x"))) != string(hs.Sum([]byte("x"))) {
+		log.Fatalln("Clone hashes differently")
+	}
+
+	hs.SetHasher(fnv.New128())
+	if c := hs.CloneHasher(); c.Hash() != hs.Hash() || c.HasherID() != HasherCustom {
+		log.Fatalln("Custom hasher should be shared")
+	}
+	if e := hs.UseHasher(HasherMMH3, []byte("secret")); e != ErrHasherKey || hs.HasherID() != HasherCustom {
+		log.Fatalln("Failed UseHasher should leave the hasher unchanged, got", e)
+	}
+}
@@ -0,0 +1,108 @@
+package partitioned
+
+import (
+	"github.com/alxdavids/bloom-filter"
+	"hash"
+	"log"
+	"xojoc.pw/bitset"
+)
+
+// PartitionedBloom splits the filter into k disjoint slices of L/k bits and
+// sets exactly one bit of slice i with the i-th hash function, so every key
+// sets k distinct bits.
+type PartitionedBloom struct {
+	bloom.Hasher                // hash function used for query and storage
+	L            uint           // Length of Bloom filter, a multiple of k
+	k            uint           // Number of hash functions and slices
+	eps          float64        // false-positive probability
+	n            uint           // predicted size of set
+	bf           *bitset.BitSet // complete array of bits
+	bs           []uint         // array of k bits from hash functions
+	c            uint           // count of elements in the Bloom filter
+}
+
+var _ bloom.Bloom = (*PartitionedBloom)(nil)
+
+func New(n uint, eps float64) bloom.Bloom {
+	var (
+		k = bloom.K(eps)
+		L = L(eps, n)
+	)
+
+	return &PartitionedBloom{
+		Hasher: bloom.DefaultHasher(),
+		k:      k,
+		L:      L,
+		eps:    eps,
+		n:      n,
+		bf:     &bitset.BitSet{},
+		bs:     make([]uint, uint(k)),
+	}
+}
+
+// L is bloom.L rounded up to a multiple of bloom.K
+func L(eps float64, n uint) uint {
+	k := bloom.K(eps)
+	return (bloom.L(eps, n) + k - 1) / k * k
+}
+
+func (this *PartitionedBloom) Add(key []byte) bloom.Bloom {
+	this.setBitset(key)
+	for _, v := range this.bs[:this.k] {
+		this.bf.Set(int(v))
+	}
+
+	this.c++
+	if this.c > this.n {
+		log.Println("Adding a greater number of elements than are expected. Expect failure.")
+	}
+
+	return this
+}
+
+func (this *PartitionedBloom) Check(key []byte) bool {
+	this.setBitset(key)
+	for _, v := range this.bs[:this.k] {
+		if !this.bf.Get(int(v)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (this *PartitionedBloom) Reset() {
+	this.k = bloom.K(this.eps)
+	this.L = L(this.eps, this.n)
+	this.bf = &bitset.BitSet{}
+	this.bs = make([]uint, this.k)
+	this.c = 0
+	this.Hasher = bloom.DefaultHasher()
+}
+
+func (this *PartitionedBloom) GetParams() (hash.Hash, uint, uint, uint, float64, *bitset.BitSet) {
+	return this.Hash(), this.L, this.k, this.n, this.eps, this.bf
+}
+
+// Count returns the number of Add calls since the last Reset
+func (this *PartitionedBloom) Count() uint {
+	return this.c
+}
+
+func (this *PartitionedBloom) Layout() bloom.Layout {
+	return bloom.LayoutPartitioned
+}
+
+func (this *PartitionedBloom) setBitset(key []byte) {
+	Indices(this.Sum(key), this.L, this.bs[:this.k])
+}
+
+// Indices is bloom.Indices for a partitioned filter of length L with len(bs)
+// slices: index i is drawn from the i-th slice of L/len(bs) bits
+func Indices(s []byte, L uint, bs []uint) {
+	m := L / uint(len(bs))
+	bloom.Indices(s, m, bs)
+	for i, _ := range bs {
+		bs[i] += uint(i) * m
+	}
+}
@@ -0,0 +1,60 @@
+package partitioned
+
+import (
+	"log"
+	"math/big"
+	"testing"
+)
+
+var (
+	n   uint = 100
+	eps      = 0.001
+)
+
+func TestPartitioned(t *testing.T) {
+	pbf := New(n, eps).(*PartitionedBloom)
+	if pbf.L%pbf.k != 0 {
+		log.Fatalln("Length is not a multiple of k:", pbf.L, pbf.k)
+	}
+
+	// Every key sets one bit in each slice
+	m := pbf.L / pbf.k
+	pbf.Add([]byte("key"))
+	for i := uint(0); i < pbf.k; i++ {
+		set := 0
+		for j := i * m; j < (i+1)*m; j++ {
+			if pbf.bf.Get(int(j)) {
+				set++
+			}
+		}
+		if set != 1 {
+			log.Fatalln("Slice", i, "has", set, "bits set")
+		}
+	}
+
+	for i := int64(0); i < int64(n); i++ {
+		pbf.Add(big.NewInt(i).Bytes())
+	}
+	for i := int64(0); i < int64(n); i++ {
+		if !pbf.Check(big.NewInt(i).Bytes()) {
+			log.Fatalln("Key not found in partitioned Bloom filter", i)
+		}
+	}
+
+	pbf.Reset()
+	if pbf.Count() != 0 || pbf.Check(big.NewInt(0).Bytes()) {
+		log.Fatalln("Reset did not clear the filter")
+	}
+}
+
+func TestIndices(t *testing.T) {
+	bs := make([]uint, 7)
+	for i := 0; i < 100; i++ {
+		Indices(big.NewInt(int64(i)).FillBytes(make([]byte, 16)), 700, bs)
+		for j, v := range bs {
+			if v/100 != uint(j) {
+				log.Fatalln("Index", v, "is outside slice", j)
+			}
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package bloom
+
+import (
+	"errors"
+	"math"
+)
+
+var (
+	ErrPlanArgs   = errors.New("bloom: exactly two of n, eps, L and k must be given")
+	ErrPlanEps    = errors.New("bloom: eps must be in (0, 1)")
+	ErrPlanLength = errors.New("bloom: L must be at least k")
+	ErrPlanUnder  = errors.New("bloom: eps and k do not determine the size of a filter")
+	ErrPlanN      = errors.New("bloom: L is too small to hold any element")
+)
+
+// Params describes a Bloom filter. Zero fields are unknown to Plan.
+type Params struct {
+	N   uint    // expected number of elements
+	Eps float64 // target false-positive rate
+	L   uint    // length in bits
+	K   uint    // number of hash functions
+	FPR float64 // expected false-positive rate with N elements, set by Plan
+}
+
+// Plan computes the unknown parameters from exactly two known ones, using
+// the optimal formulas with L and k rounded up. The capacity N is rounded
+// down when derived from L, inverting L for the rounded k when eps is given,
+// and ErrPlanN is returned if it rounds to zero.
+// Eps and k alone are rejected with ErrPlanUnder since they fix only the
+// ratio L/n.
+func Plan(p Params) (Params, error) {
+	given := 0
+	for _, v := range []bool{p.N != 0, p.Eps != 0, p.L != 0, p.K != 0} {
+		if v {
+			given++
+		}
+	}
+	if given != 2 {
+		return p, ErrPlanArgs
+	}
+	if p.Eps != 0 && !(p.Eps > 0 && p.Eps < 1) {
+		return p, ErrPlanEps
+	}
+
+	switch {
+	case p.N != 0 && p.Eps != 0:
+		p.K = K(p.Eps)
+		p.L = L(p.Eps, p.N)
+	case p.N != 0 && p.L != 0:
+		p.K = uint(math.Max(1, math.Ceil(float64(p.L)/float64(p.N)*math.Ln2)))
+	case p.N != 0 && p.K != 0:
+		p.L = uint(math.Ceil(float64(p.K) * float64(p.N) / math.Ln2))
+	case p.Eps != 0 && p.L != 0:
+		p.K = K(p.Eps)
+		k := float64(p.K)
+		p.N = uint(math.Floor(-float64(p.L) * math.Log(1-math.Pow(p.Eps, 1/k)) / k))
+	case p.L != 0 && p.K != 0:
+		p.N = uint(math.Floor(float64(p.L) * math.Ln2 / float64(p.K)))
+	default:
+		return p, ErrPlanUnder
+	}
+	if p.L < p.K {
+		return p, ErrPlanLength
+	}
+	if p.N == 0 {
+		return p, ErrPlanN
+	}
+
+	p.FPR = FalsePositiveRate(p.N, p.L, p.K)
+	if p.Eps == 0 {
+		p.Eps = p.FPR
+	}
+
+	return p, nil
+}
+
+// FalsePositiveRate is the expected false-positive rate (1 - e^(-kn/L))^k of
+// a filter of L bits with k hash functions holding n elements
+func FalsePositiveRate(n, L, k uint) float64 {
+	if L == 0 {
+		return 1
+	}
+
+	return math.Pow(1-math.Exp(-float64(k)*float64(n)/float64(L)), float64(k))
+}
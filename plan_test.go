@@ -0,0 +1,47 @@
+package bloom
+
+import (
+	"log"
+	"testing"
+)
+
+func TestPlan(t *testing.T) {
+	// Rounding up never under-provisions
+	if K(0.6) != 1 || K(0.3) != 2 || L(0.3, 10) == 0 {
+		log.Fatalln("K or L rounded down:", K(0.6), K(0.3), L(0.3, 10))
+	}
+
+	p, e := Plan(Params{N: 1000, Eps: 0.01})
+	if e != nil {
+		log.Fatalln(e)
+	}
+	if p.K != 7 || p.L < 9586 || p.L > 9700 || p.FPR > 0.01 {
+		log.Fatalln("Unexpected plan for n and eps:", p)
+	}
+
+	for _, in := range []Params{{N: 1000, L: 9586}, {N: 1000, K: 7}, {Eps: 0.01, L: 9586}, {L: 9586, K: 7},
+		{Eps: 0.3, L: 1000}, {Eps: 0.01, L: 100000}} {
+		q, e := Plan(in)
+		if e != nil {
+			log.Fatalln(e)
+		}
+		if q.N == 0 || q.L == 0 || q.K == 0 || q.Eps == 0 || q.FPR > q.Eps {
+			log.Fatalln("Incomplete plan or one that misses eps:", in, q)
+		}
+	}
+
+	errs := map[error][]Params{
+		ErrPlanArgs:   {{N: 1000}, {N: 1000, Eps: 0.01, K: 7}},
+		ErrPlanEps:    {{N: 1000, Eps: 1}, {N: 1000, Eps: -0.1}},
+		ErrPlanLength: {{L: 3, K: 7}},
+		ErrPlanUnder:  {{Eps: 0.01, K: 7}},
+		ErrPlanN:      {{L: 1, K: 1}, {Eps: 0.5, L: 1}},
+	}
+	for want, ins := range errs {
+		for _, in := range ins {
+			if _, e := Plan(in); e != want {
+				log.Fatalln("Expected", want, "for", in, "got", e)
+			}
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Package protocol runs the two-party set operations of encbf over a
+// connection. The server holds the key and its set S, the client holds a set
+// C. The server sends its encrypted filter, the client combines ciphertexts
+// for every element of C and sends them back, and the server decrypts and
+// outputs S ∪ C, S ∩ C or |S ∩ C| depending on the mode.
+//
+// Elements are treated as big-endian integers smaller than the plaintext
+// modulus of the owner's scheme, so leading zero bytes are not preserved.
+package protocol
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"github.com/alxdavids/bloom-filter/encbf"
+	"io"
+	"math/big"
+)
+
+// Upper bound on a single message, guards against corrupt length prefixes
+const maxMessageSize = 1 << 30
+
+var (
+	ErrMessageTooLarge = errors.New("protocol: message exceeds maximum size")
+	ErrMode            = errors.New("protocol: client results are for a different mode")
+)
+
+type Result struct {
+	Mode        int      // encbf.ModePSU, encbf.ModePSI or encbf.ModeCA
+	Elements    [][]byte // union or intersection, empty for ModeCA
+	Cardinality int      // size of the intersection
+	Union       int      // size of the union
+}
+
+// RunServer encrypts sbf, which must contain exactly the elements of set,
+// sends it to the client and computes the output of the requested mode from
+// the client's response.
+func RunServer(rw io.ReadWriter, owner *encbf.Owner, sbf encbf.Filter, set [][]byte, mode, maxConcurrentGoroutines int) (*Result, error) {
+	eb, e := owner.Encrypt(sbf, mode, maxConcurrentGoroutines)
+	if e != nil {
+		return nil, e
+	}
+	data, e := eb.MarshalBinary()
+	if e != nil {
+		return nil, e
+	}
+	if e := writeMsg(rw, data); e != nil {
+		return nil, e
+	}
+
+	data, e = readMsg(rw)
+	if e != nil {
+		return nil, e
+	}
+	rmode, ca, e := encbf.UnmarshalResults(owner.Public(), data)
+	if e != nil {
+		return nil, e
+	}
+	if rmode != mode {
+		return nil, ErrMode
+	}
+
+	return Interpret(owner, ca, set, mode, maxConcurrentGoroutines)
+}
+
+// Interpret decrypts the combined ciphertexts ca returned by the client and
+// computes the output of mode. set holds the elements of the Owner's filter.
+func Interpret(owner *encbf.Owner, ca [][]*big.Int, set [][]byte, mode, maxConcurrentGoroutines int) (*Result, error) {
+	res := &Result{Mode: mode}
+	var e error
+	if mode == encbf.ModeCA {
+		res.Cardinality, res.Union, e = owner.Cardinality(ca, len(set), maxConcurrentGoroutines)
+		if e != nil {
+			return nil, e
+		}
+		return res, nil
+	}
+
+	ptxts, e := owner.Decrypt(ca, maxConcurrentGoroutines)
+	if e != nil {
+		return nil, e
+	}
+	N := owner.Public().PlaintextModulus()
+	switch mode {
+	case encbf.ModePSU:
+		// Elements of C \ S decrypt to (x*s, s) with s != 0
+		res.Elements = append(res.Elements, set...)
+		for i, v := range ptxts {
+			if v.M1 == nil {
+				return nil, &encbf.ResultError{Index: i, Err: encbf.ErrInvalidEncoding}
+			}
+			if v.M1.Sign() == 0 {
+				continue
+			}
+			x := new(big.Int).ModInverse(v.M1, N)
+			x.Mul(x, v.M0).Mod(x, N)
+			res.Elements = append(res.Elements, x.Bytes())
+		}
+		res.Cardinality = len(ptxts) - (len(res.Elements) - len(set))
+		res.Union = len(res.Elements)
+	case encbf.ModePSI:
+		// Elements of S ∩ C decrypt to (x, 0)
+		for i, v := range ptxts {
+			if v.M1 == nil {
+				return nil, &encbf.ResultError{Index: i, Err: encbf.ErrInvalidEncoding}
+			}
+			if v.M1.Sign() == 0 {
+				res.Elements = append(res.Elements, v.M0.Bytes())
+			}
+		}
+		res.Cardinality = len(res.Elements)
+		res.Union = len(set) + len(ptxts) - res.Cardinality
+	}
+
+	return res, nil
+}
+
+// RunClient receives the server's encrypted filter and returns the combined
+// ciphertexts for every element of set. The client learns nothing beyond the
+// filter parameters. Cancelling ctx aborts the combination step.
+func RunClient(ctx context.Context, rw io.ReadWriter, set [][]byte, maxConcurrentGoroutines int) error {
+	data, e := readMsg(rw)
+	if e != nil {
+		return e
+	}
+	eb, e := encbf.NewPublic(data)
+	if e != nil {
+		return e
+	}
+
+	rs, e := eb.CheckBatch(ctx, set, maxConcurrentGoroutines)
+	if e != nil {
+		return e
+	}
+	ca, e := rs.Ciphertexts()
+	if e != nil {
+		return e
+	}
+
+	return writeMsg(rw, encbf.MarshalResults(rs.Mode, ca))
+}
+
+func writeMsg(w io.Writer, data []byte) error {
+	if len(data) > maxMessageSize {
+		return ErrMessageTooLarge
+	}
+	var l [4]byte
+	binary.BigEndian.PutUint32(l[:], uint32(len(data)))
+	if _, e := w.Write(l[:]); e != nil {
+		return e
+	}
+	_, e := w.Write(data)
+
+	return e
+}
+
+func readMsg(r io.Reader) ([]byte, error) {
+	var l [4]byte
+	if _, e := io.ReadFull(r, l[:]); e != nil {
+		return nil, e
+	}
+	size := binary.BigEndian.Uint32(l[:])
+	if size > maxMessageSize {
+		return nil, ErrMessageTooLarge
+	}
+	data := make([]byte, size)
+	if _, e := io.ReadFull(r, data); e != nil {
+		return nil, e
+	}
+
+	return data, nil
+}
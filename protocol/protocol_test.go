@@ -0,0 +1,182 @@
+package protocol
+
+import (
+	"context"
+	"errors"
+	"github.com/alxdavids/bloom-filter/encbf"
+	"github.com/alxdavids/bloom-filter/standard"
+	"log"
+	"math/big"
+	"net"
+	"sort"
+	"testing"
+)
+
+var (
+	n       uint = 10
+	maxConc int  = 5
+	eps          = 0.0001
+	keySize      = 512
+)
+
+// Server holds 1..10, client holds 6..15
+func sets() ([][]byte, [][]byte) {
+	server := make([][]byte, n)
+	client := make([][]byte, n)
+	for i := range server {
+		server[i] = big.NewInt(int64(i + 1)).Bytes()
+		client[i] = big.NewInt(int64(i + 6)).Bytes()
+	}
+
+	return server, client
+}
+
+func run(mode int) *Result {
+	server, client := sets()
+	sbf := standard.New(n, eps)
+	for _, v := range server {
+		sbf.Add(v)
+	}
+
+	sc, cc := net.Pipe()
+	errc := make(chan error)
+	go func() {
+		defer cc.Close()
+		errc <- RunClient(context.Background(), cc, client, maxConc)
+	}()
+
+	owner, e := encbf.NewOwner(keySize)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	res, e := RunServer(sc, owner, sbf.(*standard.StandardBloom), server, mode, maxConc)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	if e := <-errc; e != nil {
+		log.Fatalln(e)
+	}
+
+	return res
+}
+
+func ints(elems [][]byte) []int {
+	out := make([]int, len(elems))
+	for i, v := range elems {
+		out[i] = int(new(big.Int).SetBytes(v).Int64())
+	}
+	sort.Ints(out)
+
+	return out
+}
+
+func TestProtocol(t *testing.T) {
+	res := run(encbf.ModePSU)
+	union := ints(res.Elements)
+	if len(union) != 15 || res.Union != 15 || res.Cardinality != 5 {
+		log.Fatalln("Wrong union:", union)
+	}
+	for i, v := range union {
+		if v != i+1 {
+			log.Fatalln("Wrong union:", union)
+		}
+	}
+
+	res = run(encbf.ModePSI)
+	inter := ints(res.Elements)
+	if len(inter) != 5 || res.Cardinality != 5 || res.Union != 15 {
+		log.Fatalln("Wrong intersection:", inter)
+	}
+	for i, v := range inter {
+		if v != i+6 {
+			log.Fatalln("Wrong intersection:", inter)
+		}
+	}
+
+	res = run(encbf.ModeCA)
+	if res.Cardinality != 5 || res.Union != 15 || len(res.Elements) != 0 {
+		log.Fatalln("Wrong cardinalities:", res.Cardinality, res.Union)
+	}
+}
+
+// runBadClient runs the server in mode against a client that answers with
+// reply, and returns the server's error
+func runBadClient(mode int, reply func(ca [][]*big.Int) []byte) error {
+	server, client := sets()
+	sbf := standard.New(n, eps)
+	for _, v := range server {
+		sbf.Add(v)
+	}
+
+	sc, cc := net.Pipe()
+	errc := make(chan error)
+	go func() {
+		defer cc.Close()
+		data, e := readMsg(cc)
+		if e != nil {
+			errc <- e
+			return
+		}
+		eb, e := encbf.NewPublic(data)
+		if e != nil {
+			errc <- e
+			return
+		}
+		rs, e := eb.CheckBatch(context.Background(), client, maxConc)
+		if e != nil {
+			errc <- e
+			return
+		}
+		ca, e := rs.Ciphertexts()
+		if e != nil {
+			errc <- e
+			return
+		}
+		errc <- writeMsg(cc, reply(ca))
+	}()
+
+	owner, e := encbf.NewOwner(keySize)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	_, err := RunServer(sc, owner, sbf.(*standard.StandardBloom), server, mode, maxConc)
+	if e := <-errc; e != nil {
+		log.Fatalln(e)
+	}
+
+	return err
+}
+
+// Malformed client responses are rejected without crashing the server
+func TestProtocolBadClient(t *testing.T) {
+	e := runBadClient(encbf.ModePSI, func(ca [][]*big.Int) []byte {
+		return encbf.MarshalResults(encbf.ModePSU, ca)
+	})
+	if e != ErrMode {
+		log.Fatalln("Expected mode error, got", e)
+	}
+
+	e = runBadClient(encbf.ModePSI, func(ca [][]*big.Int) []byte {
+		for i := range ca {
+			ca[i] = ca[i][:1]
+		}
+		return encbf.MarshalResults(encbf.ModePSI, ca)
+	})
+	if e != encbf.ErrInvalidEncoding {
+		log.Fatalln("Expected encoding error, got", e)
+	}
+
+	// Interpret guards callers that skip UnmarshalResults
+	owner, e := encbf.NewOwner(keySize)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	c, e := owner.Public().Encrypt(big.NewInt(1))
+	if e != nil {
+		log.Fatalln(e)
+	}
+	var re *encbf.ResultError
+	if _, e := Interpret(owner, [][]*big.Int{{c}}, nil, encbf.ModePSI, maxConc); !errors.As(e, &re) {
+		log.Fatalln("Expected result error, got", e)
+	}
+}
@@ -0,0 +1,123 @@
+package scalable
+
+import (
+	"github.com/alxdavids/bloom-filter"
+	"github.com/alxdavids/bloom-filter/standard"
+	"hash"
+	"math"
+)
+
+// Defaults from Almeida et al., Scalable Bloom Filters (2007)
+const (
+	DefaultGrowth = 2   // each slice holds growth times the previous one
+	DefaultRatio  = 0.5 // each slice has ratio times the previous eps
+)
+
+// ScalableBloom is a series of StandardBloom slices. When the newest slice
+// reaches its capacity a larger one with a tighter false-positive rate is
+// appended, so that the compound rate stays below eps however many elements
+// are added.
+//
+// Slice i holds n*growth^i elements at eps*(1-ratio)*ratio^i, and the sum of
+// these rates over all slices is bounded by eps.
+type ScalableBloom struct {
+	bloom.Hasher                           // hasher given to every slice
+	n            uint                      // capacity of the first slice
+	eps          float64                   // overall false-positive probability
+	growth       uint                      // capacity growth factor
+	ratio        float64                   // tightening ratio of eps
+	sbfs         []*standard.StandardBloom // slices, the last one receives Adds
+}
+
+var _ bloom.Bloom = (*ScalableBloom)(nil)
+
+func New(n uint, eps float64) bloom.Bloom {
+	return NewWithParams(n, eps, DefaultGrowth, DefaultRatio)
+}
+
+func NewWithParams(n uint, eps float64, growth uint, ratio float64) bloom.Bloom {
+	this := &ScalableBloom{
+		n:      n,
+		eps:    eps,
+		growth: growth,
+		ratio:  ratio,
+		Hasher: bloom.DefaultHasher(),
+	}
+	this.grow()
+
+	return this
+}
+
+func (this *ScalableBloom) SetHasher(h hash.Hash) {
+	this.Hasher.SetHasher(h)
+	for _, sbf := range this.sbfs {
+		sbf.SetHasher(h)
+	}
+}
+
+// UseHasher selects the registered hash function id for all slices, key is
+// required by keyed hashers and must be nil otherwise
+func (this *ScalableBloom) UseHasher(id bloom.HasherID, key []byte) error {
+	if e := this.Hasher.UseHasher(id, key); e != nil {
+		return e
+	}
+	for _, sbf := range this.sbfs {
+		sbf.Hasher = this.CloneHasher()
+	}
+
+	return nil
+}
+
+func (this *ScalableBloom) Add(key []byte) bloom.Bloom {
+	last := this.sbfs[len(this.sbfs)-1]
+	_, _, _, n, _, _ := last.GetParams()
+	if last.Count() >= n {
+		this.grow()
+		last = this.sbfs[len(this.sbfs)-1]
+	}
+	last.Add(key)
+
+	return this
+}
+
+func (this *ScalableBloom) Check(key []byte) bool {
+	for _, sbf := range this.sbfs {
+		if sbf.Check(key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (this *ScalableBloom) Reset() {
+	this.sbfs = nil
+	this.grow()
+}
+
+// Slices returns the underlying filters, oldest first
+func (this *ScalableBloom) Slices() []*standard.StandardBloom {
+	return this.sbfs
+}
+
+// FalsePositiveRate is the bound on the false-positive probability of the
+// slices allocated so far
+func (this *ScalableBloom) FalsePositiveRate() float64 {
+	p := 1.0
+	for _, sbf := range this.sbfs {
+		_, _, _, _, eps, _ := sbf.GetParams()
+		p *= 1 - eps
+	}
+
+	return 1 - p
+}
+
+func (this *ScalableBloom) grow() {
+	i := float64(len(this.sbfs))
+	n := uint(float64(this.n) * math.Pow(float64(this.growth), i))
+	eps := this.eps * (1 - this.ratio) * math.Pow(this.ratio, i)
+
+	sbf := standard.New(n, eps).(*standard.StandardBloom)
+	sbf.Hasher = this.CloneHasher()
+	this.sbfs = append(this.sbfs, sbf)
+}
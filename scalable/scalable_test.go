@@ -0,0 +1,54 @@
+package scalable
+
+import (
+	"log"
+	"math"
+	"math/big"
+	"testing"
+)
+
+var (
+	n   uint = 100
+	eps      = 0.001
+)
+
+func TestScalable(t *testing.T) {
+	sbf := New(n, eps).(*ScalableBloom)
+
+	// Ten times the initial capacity needs several slices
+	total := int64(10 * n)
+	for i := int64(0); i < total; i++ {
+		sbf.Add(big.NewInt(i).Bytes())
+	}
+	if len(sbf.Slices()) < 3 {
+		log.Fatalln("Expected filter to grow, slices:", len(sbf.Slices()))
+	}
+	for i := int64(0); i < total; i++ {
+		if !sbf.Check(big.NewInt(i).Bytes()) {
+			log.Fatalln("Key not found in scalable Bloom filter", i)
+		}
+	}
+
+	if sbf.FalsePositiveRate() > eps {
+		log.Fatalln("False-positive bound exceeds eps:", sbf.FalsePositiveRate())
+	}
+
+	fp := 0
+	trials := int64(100000)
+	for i := total; i < total+trials; i++ {
+		if sbf.Check(big.NewInt(i).Bytes()) {
+			fp++
+		}
+	}
+	// The observed rate meets the bound within four standard deviations
+	bound := sbf.FalsePositiveRate()
+	tol := 4 * math.Sqrt(bound*(1-bound)/float64(trials))
+	if rate := float64(fp) / float64(trials); rate > bound+tol {
+		log.Fatalln("False-positive rate above the analytic bound:", rate, bound)
+	}
+
+	sbf.Reset()
+	if len(sbf.Slices()) != 1 || sbf.Check(big.NewInt(0).Bytes()) {
+		log.Fatalln("Reset did not clear the filter")
+	}
+}
@@ -0,0 +1,127 @@
+package standard
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"github.com/alxdavids/bloom-filter"
+	"math"
+	"xojoc.pw/bitset"
+)
+
+// Serialized layout (big-endian):
+//
+//	magic   [4]byte "YBSF"
+//	version uint8
+//	hasher  uint8   bloom.HasherID
+//	keychk  [8]byte bloom.KeyCheck of the hasher key
+//	L, k, n uint64
+//	eps     uint64  IEEE 754 bits
+//	c       uint64
+//	bits    [ceil(L/8)]byte, bit i at byte i/8, position i%8
+const (
+	marshalVersion    = 3
+	marshalHeaderSize = 4 + 1 + 1 + 8 + 5*8
+)
+
+var marshalMagic = [4]byte{'Y', 'B', 'S', 'F'}
+
+var (
+	ErrInvalidEncoding = errors.New("standard: invalid serialized Bloom filter")
+	ErrVersion         = errors.New("standard: unsupported serialization version")
+	ErrCustomHasher    = errors.New("standard: cannot serialize a filter with a custom hasher")
+)
+
+var (
+	_ encoding.BinaryMarshaler   = (*StandardBloom)(nil)
+	_ encoding.BinaryUnmarshaler = (*StandardBloom)(nil)
+)
+
+func (this *StandardBloom) MarshalBinary() ([]byte, error) {
+	if this.HasherID() == bloom.HasherCustom {
+		return nil, ErrCustomHasher
+	}
+
+	out := make([]byte, marshalHeaderSize+(this.L+7)/8)
+	copy(out[0:4], marshalMagic[:])
+	out[4] = marshalVersion
+	out[5] = byte(this.HasherID())
+	kc := this.HasherKeyCheck()
+	copy(out[6:14], kc[:])
+	binary.BigEndian.PutUint64(out[14:22], uint64(this.L))
+	binary.BigEndian.PutUint64(out[22:30], uint64(this.k))
+	binary.BigEndian.PutUint64(out[30:38], uint64(this.n))
+	binary.BigEndian.PutUint64(out[38:46], math.Float64bits(this.eps))
+	binary.BigEndian.PutUint64(out[46:54], uint64(this.c))
+
+	payload := out[marshalHeaderSize:]
+	for i := 0; i < int(this.L); i++ {
+		if this.bf.Get(i) {
+			payload[i/8] |= 1 << uint(i%8)
+		}
+	}
+
+	return out, nil
+}
+
+// UnmarshalBinary replaces the contents of the filter with a filter
+// previously written by MarshalBinary. Filters built with a keyed hasher need
+// UnmarshalKeyed.
+func (this *StandardBloom) UnmarshalBinary(data []byte) error {
+	return this.UnmarshalKeyed(data, nil)
+}
+
+// UnmarshalKeyed is UnmarshalBinary for filters built with a keyed hasher.
+// It returns bloom.ErrHasherMismatch if key is not the key the filter was
+// built with.
+func (this *StandardBloom) UnmarshalKeyed(data, key []byte) error {
+	if len(data) < marshalHeaderSize || string(data[0:4]) != string(marshalMagic[:]) {
+		return ErrInvalidEncoding
+	}
+	if data[4] != marshalVersion {
+		return ErrVersion
+	}
+
+	hid := bloom.HasherID(data[5])
+	var kc [8]byte
+	copy(kc[:], data[6:14])
+	if e := bloom.CheckHasher(hid, kc, key); e != nil {
+		return e
+	}
+
+	var (
+		L   = binary.BigEndian.Uint64(data[14:22])
+		k   = binary.BigEndian.Uint64(data[22:30])
+		n   = binary.BigEndian.Uint64(data[30:38])
+		eps = math.Float64frombits(binary.BigEndian.Uint64(data[38:46]))
+		c   = binary.BigEndian.Uint64(data[46:54])
+	)
+	if L == 0 || k == 0 || k > L || k > bloom.MaxK || !(eps > 0 && eps < 1) ||
+		uint64(len(data)-marshalHeaderSize) != (L+7)/8 {
+		return ErrInvalidEncoding
+	}
+
+	bf := &bitset.BitSet{}
+	for i, b := range data[marshalHeaderSize:] {
+		for j := 0; b != 0; j++ {
+			if b&1 == 1 {
+				bf.Set(i*8 + j)
+			}
+			b >>= 1
+		}
+	}
+	if bf.Len() > int(L) {
+		return ErrInvalidEncoding
+	}
+
+	this.UseHasher(hid, key)
+	this.L = uint(L)
+	this.k = uint(k)
+	this.eps = eps
+	this.n = uint(n)
+	this.bf = bf
+	this.bs = make([]uint, uint(k))
+	this.c = uint(c)
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package standard
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"github.com/alxdavids/bloom-filter"
+	"log"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestMarshal(t *testing.T) {
+	sbf := New(n, eps).(*StandardBloom)
+	keys := make([][]byte, n)
+	for i := range keys {
+		r, e := rand.Int(rand.Reader, big.NewInt(max))
+		if e != nil {
+			log.Fatalln(e)
+		}
+		keys[i] = r.Bytes()
+		sbf.Add(keys[i])
+	}
+
+	data, e := sbf.MarshalBinary()
+	if e != nil {
+		log.Fatalln(e)
+	}
+
+	loaded := &StandardBloom{}
+	if e := loaded.UnmarshalBinary(data); e != nil {
+		log.Fatalln(e)
+	}
+
+	if loaded.L != sbf.L || loaded.k != sbf.k || loaded.n != sbf.n || loaded.eps != sbf.eps || loaded.c != sbf.c {
+		log.Fatalln("Parameters differ after unmarshalling")
+	}
+	if !loaded.bf.Equal(sbf.bf) {
+		log.Fatalln("Bit array differs after unmarshalling")
+	}
+	for i := int64(0); i < max; i++ {
+		key := big.NewInt(i).Bytes()
+		if loaded.Check(key) != sbf.Check(key) {
+			log.Fatalln("Check results differ after unmarshalling")
+		}
+	}
+
+	data[4] = marshalVersion + 1
+	if e := loaded.UnmarshalBinary(data); e != ErrVersion {
+		log.Fatalln("Expected version error, got", e)
+	}
+	data[4] = marshalVersion
+	if e := loaded.UnmarshalBinary(data[:marshalHeaderSize-1]); e != ErrInvalidEncoding {
+		log.Fatalln("Expected encoding error, got", e)
+	}
+
+	// Tampered headers: k out of range and eps outside (0, 1)
+	for _, tamper := range []struct {
+		off int
+		v   uint64
+	}{{22, 1 << 62}, {22, uint64(sbf.L) + 1}, {38, math.Float64bits(0)}, {38, math.Float64bits(1)}, {38, math.Float64bits(math.NaN())}} {
+		bad := append([]byte(nil), data...)
+		binary.BigEndian.PutUint64(bad[tamper.off:tamper.off+8], tamper.v)
+		if e := loaded.UnmarshalBinary(bad); e != ErrInvalidEncoding {
+			log.Fatalln("Expected encoding error for tampered header, got", e)
+		}
+	}
+
+	sbf.SetHasher(sbf.Hash())
+	if _, e := sbf.MarshalBinary(); e != ErrCustomHasher {
+		log.Fatalln("Expected custom hasher error, got", e)
+	}
+}
+
+func TestMarshalKeyed(t *testing.T) {
+	key := []byte("shared secret")
+	sbf := New(n, eps).(*StandardBloom)
+	if e := sbf.UseHasher(bloom.HasherHMACSHA256, key); e != nil {
+		log.Fatalln(e)
+	}
+	sbf.Add([]byte("element"))
+
+	data, e := sbf.MarshalBinary()
+	if e != nil {
+		log.Fatalln(e)
+	}
+	loaded := &StandardBloom{}
+	if e := loaded.UnmarshalBinary(data); e != bloom.ErrHasherKey {
+		log.Fatalln("Expected key error, got", e)
+	}
+	if e := loaded.UnmarshalKeyed(data, []byte("wrong")); e != bloom.ErrHasherMismatch {
+		log.Fatalln("Expected mismatch error, got", e)
+	}
+	if e := loaded.UnmarshalKeyed(data, key); e != nil {
+		log.Fatalln(e)
+	}
+	if loaded.HasherID() != bloom.HasherHMACSHA256 || !loaded.Check([]byte("element")) {
+		log.Fatalln("Keyed filter differs after unmarshalling")
+	}
+
+	// The same element sets different bits without the key
+	plain := New(n, eps).(*StandardBloom)
+	plain.Add([]byte("element"))
+	if plain.bf.Equal(sbf.bf) {
+		log.Fatalln("Keyed hashing should change the bits set")
+	}
+}
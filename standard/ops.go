@@ -0,0 +1,70 @@
+package standard
+
+import (
+	"errors"
+	"github.com/alxdavids/bloom-filter"
+	"math"
+)
+
+var ErrIncompatible = errors.New("standard: filters have different parameters or hashers")
+
+// Union returns a filter containing the elements of both filters. It is the
+// filter that adding both sets to one filter would have built.
+func (this *StandardBloom) Union(other *StandardBloom) (*StandardBloom, error) {
+	out, e := this.combine(other)
+	if e != nil {
+		return nil, e
+	}
+	out.bf.Union(other.bf)
+	out.c = uint(math.Round(out.EstimateCount()))
+
+	return out, nil
+}
+
+// Intersect returns a filter containing the elements common to both filters.
+// Bits set by different elements in each filter survive, so its
+// false-positive rate is at least that of a filter built from the
+// intersection directly.
+func (this *StandardBloom) Intersect(other *StandardBloom) (*StandardBloom, error) {
+	out, e := this.combine(other)
+	if e != nil {
+		return nil, e
+	}
+	out.bf.Intersect(other.bf)
+	out.c = uint(math.Round(out.EstimateCount()))
+
+	return out, nil
+}
+
+// EstimateIntersection estimates the number of elements common to both
+// filters as n(A) + n(B) - n(A ∪ B), where n is EstimateCount.
+func (this *StandardBloom) EstimateIntersection(other *StandardBloom) (float64, error) {
+	union, e := this.Union(other)
+	if e != nil {
+		return 0, e
+	}
+	est := this.EstimateCount() + other.EstimateCount() - union.EstimateCount()
+
+	return math.Max(0, est), nil
+}
+
+// combine checks that other was built the same way as this and returns a copy
+// of this to combine other into
+func (this *StandardBloom) combine(other *StandardBloom) (*StandardBloom, error) {
+	if this.L != other.L || this.k != other.k || this.HasherID() != other.HasherID() ||
+		this.HasherKeyCheck() != other.HasherKeyCheck() {
+		return nil, ErrIncompatible
+	}
+	// Custom hashers cannot be compared, only a shared instance is accepted
+	if this.HasherID() == bloom.HasherCustom && this.Hash() != other.Hash() {
+		return nil, ErrIncompatible
+	}
+
+	out := FromBitset(this.n, this.eps, this.bf.Clone(), this.c)
+	out.L = this.L
+	out.k = this.k
+	out.bs = make([]uint, this.k)
+	out.Hasher = this.CloneHasher()
+
+	return out, nil
+}
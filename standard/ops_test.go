@@ -0,0 +1,84 @@
+package standard
+
+import (
+	"github.com/alxdavids/bloom-filter"
+	"log"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestOps(t *testing.T) {
+	size := uint(1000)
+	a := New(size, eps).(*StandardBloom)
+	b := New(size, eps).(*StandardBloom)
+	// a holds [0, 600), b holds [400, 1000)
+	for i := int64(0); i < 600; i++ {
+		a.Add(big.NewInt(i).Bytes())
+		b.Add(big.NewInt(i + 400).Bytes())
+	}
+
+	union, e := a.Union(b)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	inter, e := a.Intersect(b)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	for i := int64(0); i < 1000; i++ {
+		if !union.Check(big.NewInt(i).Bytes()) {
+			log.Fatalln("Union is missing", i)
+		}
+		if i >= 400 && i < 600 && !inter.Check(big.NewInt(i).Bytes()) {
+			log.Fatalln("Intersection is missing", i)
+		}
+	}
+	if a.bf.Cardinality() == union.bf.Cardinality() {
+		log.Fatalln("Union should not modify its receiver's bits")
+	}
+
+	est, e := a.EstimateIntersection(b)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	if math.Abs(est-200) > 20 {
+		log.Fatalln("Poor intersection estimate:", est)
+	}
+	if math.Abs(float64(union.Count())-1000) > 50 {
+		log.Fatalln("Poor union count estimate:", union.Count())
+	}
+
+	// Incompatible parameters and hashers are rejected
+	if _, e := a.Union(New(2*size, eps).(*StandardBloom)); e != ErrIncompatible {
+		log.Fatalln("Expected incompatible error, got", e)
+	}
+	c := New(size, eps).(*StandardBloom)
+	c.UseHasher(bloom.HasherSHA256, nil)
+	if _, e := a.Intersect(c); e != ErrIncompatible {
+		log.Fatalln("Expected incompatible error, got", e)
+	}
+	d := New(size, eps).(*StandardBloom)
+	d.UseHasher(bloom.HasherHMACSHA256, []byte("one"))
+	f := New(size, eps).(*StandardBloom)
+	f.UseHasher(bloom.HasherHMACSHA256, []byte("two"))
+	if _, e := d.Union(f); e != ErrIncompatible {
+		log.Fatalln("Expected incompatible error for different keys, got", e)
+	}
+}
+
+// Filters restored with a k other than K(eps) combine with their own k
+func TestOpsUnmarshalledK(t *testing.T) {
+	a := New(n, eps).(*StandardBloom)
+	a.k += 2
+	a.bs = make([]uint, a.k)
+	a.Add([]byte("b"))
+
+	union, e := a.Union(a)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	if union.k != a.k || !union.Check([]byte("b")) {
+		log.Fatalln("Union lost the number of hash functions")
+	}
+}
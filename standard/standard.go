@@ -1,23 +1,22 @@
 package standard
 
 import (
-	"encoding/binary"
 	"github.com/alxdavids/bloom-filter"
-	"github.com/reusee/mmh3"
 	"hash"
 	"log"
+	"math"
 	"xojoc.pw/bitset"
 )
 
 type StandardBloom struct {
-	h   hash.Hash      // hash function used for query and storage
-	L   uint           // Length of Bloom filter
-	k   uint           // Number of hash functions
-	eps float64        // false-positive probability
-	n   uint           // predicted size of set
-	bf  *bitset.BitSet // complete array of bits
-	bs  []uint         // array of k bits from hash functions
-	c   uint           // count of elements in the Bloom filter
+	bloom.Hasher                // hash function used for query and storage
+	L            uint           // Length of Bloom filter
+	k            uint           // Number of hash functions
+	eps          float64        // false-positive probability
+	n            uint           // predicted size of set
+	bf           *bitset.BitSet // complete array of bits
+	bs           []uint         // array of k bits from hash functions
+	c            uint           // count of elements in the Bloom filter
 }
 
 var _ bloom.Bloom = (*StandardBloom)(nil)
@@ -30,19 +29,26 @@ func New(n uint, eps float64) bloom.Bloom {
 	)
 
 	return &StandardBloom{
-		h:   mmh3.New128(),
-		k:   k,
-		L:   L,
-		eps: eps,
-		n:   n,
-		bf:  &bitset.BitSet{},
-		bs:  make([]uint, uint(k)),
-		c:   c,
+		Hasher: bloom.DefaultHasher(),
+		k:      k,
+		L:      L,
+		eps:    eps,
+		n:      n,
+		bf:     &bitset.BitSet{},
+		bs:     make([]uint, uint(k)),
+		c:      c,
 	}
 }
 
-func (this *StandardBloom) SetHasher(h hash.Hash) {
-	this.h = h
+// FromBitset builds a filter with the parameters of New(n, eps) around an
+// existing bit array, so that other filter types using the same indexing can
+// be viewed as a StandardBloom. c is the number of elements represented.
+func FromBitset(n uint, eps float64, bf *bitset.BitSet, c uint) *StandardBloom {
+	sbf := New(n, eps).(*StandardBloom)
+	sbf.bf = bf
+	sbf.c = c
+
+	return sbf
 }
 
 func (this *StandardBloom) Add(key []byte) bloom.Bloom {
@@ -76,27 +82,44 @@ func (this *StandardBloom) Reset() {
 	this.bf = &bitset.BitSet{}
 	this.bs = make([]uint, this.k)
 	this.c = 0
-	this.h = mmh3.New128()
+	this.Hasher = bloom.DefaultHasher()
 }
 
 func (this *StandardBloom) GetParams() (hash.Hash, uint, uint, uint, float64, *bitset.BitSet) {
-	return this.h, this.L, this.k, this.n, this.eps, this.bf
+	return this.Hash(), this.L, this.k, this.n, this.eps, this.bf
 }
 
-func (this *StandardBloom) setBitset(key []byte) {
-	this.h.Reset()
-	h := this.h
-	_, e := h.Write(key)
-	if e != nil {
-		log.Println(e)
-	}
-	s := h.Sum(nil)
-	// Reference: Less Hashing, Same Performance: Building a Better Bloom Filter
-	// URL: http://www.eecs.harvard.edu/~kirsch/pubs/bbbf/rsa.pdf
-	s1 := binary.BigEndian.Uint32(s[0:4])
-	s2 := binary.BigEndian.Uint32(s[4:8])
-
-	for i, _ := range this.bs[:this.k] {
-		this.bs[i] = (uint(s1) + uint(i)*uint(s2)) % this.L
+// Count returns the number of Add calls since the last Reset, or an estimate
+// for filters returned by Union and Intersect
+func (this *StandardBloom) Count() uint {
+	return this.c
+}
+
+// EstimateCount is the expected number of distinct elements given the number
+// of bits set, -L/k * ln(1 - X/L) (Swamidass and Baldi, 2007). Unlike Count it
+// ignores duplicates and survives Union and unmarshalling. It is +Inf for a
+// filter with every bit set.
+func (this *StandardBloom) EstimateCount() float64 {
+	x := float64(this.bf.Cardinality())
+	if x >= float64(this.L) {
+		return math.Inf(1)
 	}
+
+	return -float64(this.L) / float64(this.k) * math.Log(1-x/float64(this.L))
+}
+
+// EstimateFalsePositiveRate is the probability (X/L)^k that a key not in the
+// filter finds all k of its bits set, given the X bits currently set. It
+// exceeds eps once the filter is saturated.
+func (this *StandardBloom) EstimateFalsePositiveRate() float64 {
+	return math.Pow(float64(this.bf.Cardinality())/float64(this.L), float64(this.k))
+}
+
+func (this *StandardBloom) Layout() bloom.Layout {
+	return bloom.LayoutStandard
+}
+
+func (this *StandardBloom) setBitset(key []byte) {
+	s := this.Sum(key)
+	bloom.Indices(s, this.L, this.bs[:this.k])
 }
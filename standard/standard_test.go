@@ -3,6 +3,7 @@ package standard
 import (
 	"crypto/rand"
 	"log"
+	"math"
 	"math/big"
 	"testing"
 )
@@ -34,3 +35,30 @@ func TestBloom(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestEstimates(t *testing.T) {
+	sbf := New(n, eps).(*StandardBloom)
+	if sbf.EstimateCount() != 0 || sbf.EstimateFalsePositiveRate() != 0 {
+		log.Fatalln("Empty filter should have no elements")
+	}
+
+	// Duplicates are counted by Count but not by EstimateCount
+	for i := int64(0); i < int64(n); i++ {
+		sbf.Add(big.NewInt(i).Bytes())
+		sbf.Add(big.NewInt(i).Bytes())
+	}
+	if sbf.Count() != 2*n || math.Abs(sbf.EstimateCount()-float64(n)) > 0.1*float64(n) {
+		log.Fatalln("Poor count estimate:", sbf.Count(), sbf.EstimateCount())
+	}
+	if fpr := sbf.EstimateFalsePositiveRate(); fpr > 2*eps {
+		log.Fatalln("False-positive estimate too high at capacity:", fpr)
+	}
+
+	// Twice over capacity the filter is saturated
+	for i := int64(n); i < 2*int64(n); i++ {
+		sbf.Add(big.NewInt(i).Bytes())
+	}
+	if fpr := sbf.EstimateFalsePositiveRate(); fpr < 100*eps {
+		log.Fatalln("Saturation not reflected in false-positive estimate:", fpr)
+	}
+}